@@ -0,0 +1,282 @@
+package alert
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var engine *Engine
+
+func GetEngine() *Engine {
+	return engine
+}
+
+// Init creates the targets, parses the configured rules and evaluates them on every refresh.
+func Init() {
+	httpClient := &http.Client{
+		Timeout: time.Duration(config.Conf.AlertTimeoutMs) * time.Millisecond,
+	}
+	initTargets(httpClient)
+
+	rules := make([]*Rule, 0, len(config.Conf.Alerts))
+	for _, a := range config.Conf.Alerts {
+		rule, err := NewRule(a)
+		if err != nil {
+			log.Errorf("invalid alert rule %q, err: %v\n", a.Name, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	engine = NewEngine(rules, NewWebhookSender(httpClient))
+	client.GetServerContainer().AddListener(engine.Evaluate)
+	client.GetServerContainer().AddChangeListener(engine.forget)
+}
+
+type Rule struct {
+	Name      string
+	Server    string
+	Expr      string
+	Condition Condition
+	Duration  time.Duration
+	Cooldown  time.Duration
+	Webhook   string
+	BodyTmpl  *template.Template
+	Target    string
+}
+
+func NewRule(a *config.Alert) (*Rule, error) {
+	if a.Webhook == "" && a.Target == "" {
+		return nil, fmt.Errorf("webhook or target is required")
+	}
+	if _, ok := targets[a.Target]; a.Target != "" && !ok {
+		return nil, fmt.Errorf("unknown target %q", a.Target)
+	}
+	cond, duration, err := ParseCondition(a.Condition)
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{
+		Name:      a.Name,
+		Server:    a.Server,
+		Expr:      a.Condition,
+		Condition: cond,
+		Duration:  duration,
+		Cooldown:  time.Duration(a.Cooldown) * time.Second,
+		Webhook:   a.Webhook,
+		Target:    a.Target,
+	}
+	if a.Body != "" {
+		rule.BodyTmpl, err = template.New(a.Name).Funcs(templateFuncs).Parse(a.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+	}
+	return rule, nil
+}
+
+func (r *Rule) matchServer(conf client.ServerConfig) bool {
+	return r.Server == "" || r.Server == conf.Id || r.Server == conf.Address() || r.Server == conf.DisplayName
+}
+
+// Alert is a fired rule.
+type Alert struct {
+	Rule        string        `json:"rule"`
+	ServerId    string        `json:"server_id"`
+	Server      string        `json:"server"`
+	Address     string        `json:"address"`
+	Condition   string        `json:"condition"`
+	Message     string        `json:"message"`
+	Status      client.Status `json:"status"`
+	PlayerCount int64         `json:"player_count"`
+	MaxPlayers  int64         `json:"max_players"`
+	Map         string        `json:"map"`
+	Players     []string      `json:"players"`
+	Player      string        `json:"player,omitempty"`
+	// unix time in seconds
+	Time int64 `json:"time"`
+}
+
+// Record is a fired alert with the result of its delivery.
+type Record struct {
+	*Alert
+	Delivered bool   `json:"delivered"`
+	Attempts  int64  `json:"attempts"`
+	Error     string `json:"error,omitempty"`
+}
+
+// stateKey is a rule, by its index, on a server.
+type stateKey struct {
+	rule     int
+	serverId string
+}
+
+type ruleState struct {
+	since     time.Time
+	firing    bool
+	lastFired time.Time
+}
+
+// Engine evaluates the rules, a rule fires once when its condition has held for the duration
+// and again only after the condition cleared and the cooldown passed.
+type Engine struct {
+	rules   []*Rule
+	sender  Sender
+	states  map[stateKey]*ruleState
+	players map[*client.Server][]*client.Player
+	records []*Record
+	mu      sync.Mutex
+}
+
+func NewEngine(rules []*Rule, sender Sender) *Engine {
+	return &Engine{
+		rules:   rules,
+		sender:  sender,
+		states:  make(map[stateKey]*ruleState),
+		players: make(map[*client.Server][]*client.Player),
+		records: make([]*Record, 0),
+	}
+}
+
+func (e *Engine) Evaluate(server *client.Server) {
+	fired := e.evaluate(server, server.Config(), server.State(), time.Now())
+	for rule, alert := range fired {
+		log.Infof("alert fired, rule: %s, server: %s, message: %s\n", alert.Rule, alert.Address, alert.Message)
+		go e.deliver(rule, alert)
+	}
+}
+
+// evaluate updates the rule states with the state of the server at now and returns the alerts to deliver.
+func (e *Engine) evaluate(server *client.Server, conf client.ServerConfig, state client.ServerState, now time.Time) map[*Rule]*Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// nil until the players of the server are known
+	previous := e.players[server]
+	if state.Info != nil && state.Status != client.StatusOffline && !(len(state.Info.Players) == 0 && state.Info.PlayerCount > 0) {
+		players := make([]*client.Player, 0, len(state.Info.Players))
+		e.players[server] = append(players, state.Info.Players...)
+	}
+
+	fired := make(map[*Rule]*Alert)
+	for i, rule := range e.rules {
+		if !rule.matchServer(conf) {
+			continue
+		}
+		ok, message := rule.Condition.Check(state, previous)
+		key := stateKey{rule: i, serverId: conf.Id}
+		rs, exists := e.states[key]
+		if !exists {
+			rs = &ruleState{}
+			e.states[key] = rs
+		}
+		if !ok {
+			rs.since = time.Time{}
+			rs.firing = false
+			continue
+		}
+		if rs.since.IsZero() {
+			rs.since = now
+		}
+		// dedupe, a condition which keeps holding fires only once
+		if rs.firing && !rule.Condition.Instant() {
+			continue
+		}
+		if now.Sub(rs.since) < rule.Duration {
+			continue
+		}
+		if !rs.lastFired.IsZero() && now.Sub(rs.lastFired) < rule.Cooldown {
+			log.Debugf("alert skipped in cooldown, rule: %s, server: %s\n", rule.Name, conf.Address())
+			continue
+		}
+		rs.firing = true
+		rs.lastFired = now
+		fired[rule] = newAlert(rule, conf, state, message, now)
+	}
+	return fired
+}
+
+// forget drops the states of a removed server, and of an edited one as its config may no longer match them.
+func (e *Engine) forget(server *client.Server, change client.Change) {
+	if change == client.ChangeAdded {
+		return
+	}
+	id := server.Config().Id
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.players, server)
+	for key := range e.states {
+		if key.serverId == id {
+			delete(e.states, key)
+		}
+	}
+}
+
+func newAlert(rule *Rule, conf client.ServerConfig, state client.ServerState, message string, now time.Time) *Alert {
+	alert := &Alert{
+		Rule:      rule.Name,
+		ServerId:  conf.Id,
+		Server:    conf.DisplayName,
+		Address:   conf.Address(),
+		Condition: rule.Expr,
+		Message:   message,
+		Status:    state.Status,
+		Time:      now.Unix(),
+		Players:   make([]string, 0),
+	}
+	if state.Info != nil {
+		alert.PlayerCount = state.Info.PlayerCount
+		alert.MaxPlayers = state.Info.MaxPlayers
+		alert.Map = state.Info.Map
+		for _, p := range state.Info.Players {
+			if p != nil && p.Name != "" {
+				alert.Players = append(alert.Players, p.Name)
+			}
+		}
+		if alert.Server == "" {
+			alert.Server = state.Info.ServerName
+		}
+	}
+	if c, ok := rule.Condition.(*playerCondition); ok {
+		alert.Player = c.name
+	}
+	if alert.Server == "" {
+		alert.Server = alert.Address
+	}
+	return alert
+}
+
+func (e *Engine) deliver(rule *Rule, alert *Alert) {
+	record := &Record{Alert: alert}
+	attempts, err := e.sender.Send(rule, alert)
+	record.Attempts = attempts
+	if err != nil {
+		log.Warnf("deliver alert failed, rule: %s, err: %v\n", alert.Rule, err)
+		record.Error = err.Error()
+	} else {
+		record.Delivered = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.records = append(e.records, record)
+	size := int(config.Conf.AlertHistorySize)
+	if size > 0 && len(e.records) > size {
+		e.records = e.records[len(e.records)-size:]
+	}
+}
+
+// Records returns the fired alerts, newest first.
+func (e *Engine) Records() []*Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	records := make([]*Record, 0, len(e.records))
+	for i := len(e.records) - 1; i >= 0; i-- {
+		records = append(records, e.records[i])
+	}
+	return records
+}
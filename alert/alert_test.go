@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// logToTempDir keeps the log of a test out of the package directory.
+func logToTempDir(t *testing.T) {
+	log.SetPath(filepath.Join(t.TempDir(), "log.log"))
+	t.Cleanup(func() {
+		log.SetPath("log.log")
+	})
+}
+
+func newTestEngine(t *testing.T, conf *config.Alert) (*Engine, *client.Server) {
+	logToTempDir(t)
+	conf.Name = "test"
+	conf.Webhook = "http://127.0.0.1/"
+	rule, err := NewRule(conf)
+	if err != nil {
+		t.Fatalf("NewRule failed, err: %v", err)
+	}
+	server := client.NewServer(client.ServerConfig{Ip: "127.0.0.1", Port: 27015})
+	return NewEngine([]*Rule{rule}, nil), server
+}
+
+func onlineState(playerCount int64) client.ServerState {
+	return client.ServerState{
+		Status: client.StatusOnline,
+		Info:   &client.Info{PlayerCount: playerCount, MaxPlayers: 32},
+	}
+}
+
+func TestEngineDedupe(t *testing.T) {
+	e, server := newTestEngine(t, &config.Alert{Condition: "player_count >= 20 for 1m"})
+	conf := server.Config()
+	start := time.Now()
+
+	steps := []struct {
+		after       time.Duration
+		playerCount int64
+		fire        bool
+	}{
+		{after: 0, playerCount: 20, fire: false},
+		// the duration has not passed
+		{after: 30 * time.Second, playerCount: 25, fire: false},
+		{after: time.Minute, playerCount: 25, fire: true},
+		// still holding, fired already
+		{after: 2 * time.Minute, playerCount: 30, fire: false},
+		{after: 3 * time.Minute, playerCount: 10, fire: false},
+		// holds again, the duration starts over
+		{after: 4 * time.Minute, playerCount: 20, fire: false},
+		{after: 5 * time.Minute, playerCount: 20, fire: true},
+	}
+	for i, step := range steps {
+		fired := e.evaluate(server, conf, onlineState(step.playerCount), start.Add(step.after))
+		if (len(fired) > 0) != step.fire {
+			t.Errorf("step %d: fired %d alerts, want fire %v", i, len(fired), step.fire)
+		}
+	}
+}
+
+func TestEngineCooldown(t *testing.T) {
+	e, server := newTestEngine(t, &config.Alert{Condition: "player_count >= 20", Cooldown: 600})
+	conf := server.Config()
+	start := time.Now()
+
+	steps := []struct {
+		after       time.Duration
+		playerCount int64
+		fire        bool
+	}{
+		{after: 0, playerCount: 20, fire: true},
+		{after: time.Minute, playerCount: 10, fire: false},
+		// cleared and holds again within the cooldown
+		{after: 2 * time.Minute, playerCount: 20, fire: false},
+		{after: 3 * time.Minute, playerCount: 10, fire: false},
+		{after: 10 * time.Minute, playerCount: 20, fire: true},
+	}
+	for i, step := range steps {
+		fired := e.evaluate(server, conf, onlineState(step.playerCount), start.Add(step.after))
+		if (len(fired) > 0) != step.fire {
+			t.Errorf("step %d: fired %d alerts, want fire %v", i, len(fired), step.fire)
+		}
+	}
+}
+
+func TestEngineInstantCondition(t *testing.T) {
+	e, server := newTestEngine(t, &config.Alert{Condition: `player "X" joined`})
+	conf := server.Config()
+	now := time.Now()
+
+	state := onlineState(1)
+	state.Info.Players = []*client.Player{{Name: "Y"}}
+	if fired := e.evaluate(server, conf, state, now); len(fired) != 0 {
+		t.Errorf("fired on the first refresh")
+	}
+	state = onlineState(2)
+	state.Info.Players = []*client.Player{{Name: "Y"}, {Name: "X"}}
+	fired := e.evaluate(server, conf, state, now.Add(time.Second))
+	if len(fired) != 1 {
+		t.Fatalf("fired %d alerts, want 1", len(fired))
+	}
+	for _, alert := range fired {
+		if alert.Player != "X" || alert.PlayerCount != 2 {
+			t.Errorf("alert = %+v", alert)
+		}
+	}
+	if fired := e.evaluate(server, conf, state, now.Add(2*time.Second)); len(fired) != 0 {
+		t.Errorf("fired again while the player stays")
+	}
+}
+
+func TestEngineForget(t *testing.T) {
+	e, server := newTestEngine(t, &config.Alert{Condition: "player_count >= 20", Cooldown: 600})
+	conf := server.Config()
+	now := time.Now()
+
+	state := onlineState(20)
+	state.Info.Players = []*client.Player{{Name: "X"}}
+	if fired := e.evaluate(server, conf, state, now); len(fired) != 1 {
+		t.Fatalf("fired %d alerts, want 1", len(fired))
+	}
+	e.forget(server, client.ChangeAdded)
+	if len(e.states) != 1 || len(e.players) != 1 {
+		t.Errorf("states dropped on add")
+	}
+	e.forget(server, client.ChangeUpdated)
+	if len(e.states) != 0 || len(e.players) != 0 {
+		t.Errorf("states = %d, players = %d, want none", len(e.states), len(e.players))
+	}
+	// the cooldown is gone with the state
+	if fired := e.evaluate(server, conf, state, now.Add(time.Second)); len(fired) != 1 {
+		t.Errorf("fired %d alerts after update, want 1", len(fired))
+	}
+	e.forget(server, client.ChangeRemoved)
+	if len(e.states) != 0 || len(e.players) != 0 {
+		t.Errorf("states = %d, players = %d, want none", len(e.states), len(e.players))
+	}
+}
@@ -0,0 +1,174 @@
+package alert
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Condition is checked against every refresh of a server.
+type Condition interface {
+	// Check returns whether the condition holds and a message describing the state.
+	// previous is the players of the last refresh, nil on the first one.
+	Check(state client.ServerState, previous []*client.Player) (bool, string)
+	// Instant conditions are events which hold for one refresh only, the duration does not apply.
+	Instant() bool
+}
+
+var (
+	forPattern     = regexp.MustCompile(`^(.*?)\s+for\s+(\S+)$`)
+	comparePattern = regexp.MustCompile(`^([a-z_]+)\s*(>=|<=|==|!=|>|<)\s*(-?\d+(?:\.\d+)?)$`)
+	playerPattern  = regexp.MustCompile(`^player\s+"(.*)"\s+(joined|left)$`)
+)
+
+// ParseCondition parses expressions like `player_count >= 20 for 5m`, `offline for 2m` or `player "X" joined`.
+func ParseCondition(expr string) (Condition, time.Duration, error) {
+	expr = strings.TrimSpace(expr)
+	var duration time.Duration = 0
+	if m := forPattern.FindStringSubmatch(expr); m != nil {
+		d, err := time.ParseDuration(m[2])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid duration %q: %w", m[2], err)
+		}
+		if d < 0 {
+			return nil, 0, fmt.Errorf("negative duration %q", m[2])
+		}
+		expr = strings.TrimSpace(m[1])
+		duration = d
+	}
+
+	var cond Condition
+	switch {
+	case expr == string(client.StatusOnline) || expr == string(client.StatusOffline) || expr == string(client.StatusDegraded):
+		cond = &statusCondition{status: client.Status(expr)}
+	case playerPattern.MatchString(expr):
+		m := playerPattern.FindStringSubmatch(expr)
+		cond = &playerCondition{name: m[1], joined: m[2] == "joined"}
+	case comparePattern.MatchString(expr):
+		m := comparePattern.FindStringSubmatch(expr)
+		if _, ok := fields[m[1]]; !ok {
+			return nil, 0, fmt.Errorf("unknown field %q", m[1])
+		}
+		value, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		cond = &compareCondition{field: m[1], op: m[2], value: value}
+	default:
+		return nil, 0, fmt.Errorf("invalid condition %q", expr)
+	}
+	if cond.Instant() && duration > 0 {
+		return nil, 0, fmt.Errorf("duration is not supported by %q", expr)
+	}
+	return cond, duration, nil
+}
+
+type statusCondition struct {
+	status client.Status
+}
+
+func (c *statusCondition) Check(state client.ServerState, previous []*client.Player) (bool, string) {
+	if state.Status != c.status {
+		return false, ""
+	}
+	if state.Status == client.StatusOnline {
+		return true, "server is online"
+	}
+	return true, fmt.Sprintf("server is %s: %s", state.Status, state.LastError)
+}
+
+func (c *statusCondition) Instant() bool {
+	return false
+}
+
+var fields = map[string]func(state client.ServerState) float64{
+	"player_count": func(state client.ServerState) float64 {
+		return float64(state.Info.PlayerCount)
+	},
+	"max_players": func(state client.ServerState) float64 {
+		return float64(state.Info.MaxPlayers)
+	},
+	"bots": func(state client.ServerState) float64 {
+		return float64(state.Info.Bots)
+	},
+	"latency_ms": func(state client.ServerState) float64 {
+		return state.Info.LatencyMs
+	},
+	"failure_count": func(state client.ServerState) float64 {
+		return float64(state.FailureCount)
+	},
+}
+
+type compareCondition struct {
+	field string
+	op    string
+	value float64
+}
+
+func (c *compareCondition) Check(state client.ServerState, previous []*client.Player) (bool, string) {
+	// the info fields are stale while the server is offline
+	if c.field != "failure_count" && (state.Info == nil || state.Status == client.StatusOffline) {
+		return false, ""
+	}
+	v := fields[c.field](state)
+	var ok bool
+	switch c.op {
+	case ">=":
+		ok = v >= c.value
+	case "<=":
+		ok = v <= c.value
+	case ">":
+		ok = v > c.value
+	case "<":
+		ok = v < c.value
+	case "==":
+		ok = v == c.value
+	case "!=":
+		ok = v != c.value
+	}
+	return ok, fmt.Sprintf("%s is %s", c.field, strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func (c *compareCondition) Instant() bool {
+	return false
+}
+
+type playerCondition struct {
+	name   string
+	joined bool
+}
+
+func (c *playerCondition) Check(state client.ServerState, previous []*client.Player) (bool, string) {
+	if previous == nil || state.Info == nil || state.Status == client.StatusOffline {
+		return false, ""
+	}
+	// an empty list with players online means the player query failed
+	if len(state.Info.Players) == 0 && state.Info.PlayerCount > 0 {
+		return false, ""
+	}
+	before := hasPlayer(previous, c.name)
+	now := hasPlayer(state.Info.Players, c.name)
+	if c.joined && !before && now {
+		return true, fmt.Sprintf("player %s joined", c.name)
+	}
+	if !c.joined && before && !now {
+		return true, fmt.Sprintf("player %s left", c.name)
+	}
+	return false, ""
+}
+
+func (c *playerCondition) Instant() bool {
+	return true
+}
+
+func hasPlayer(players []*client.Player, name string) bool {
+	for _, p := range players {
+		if p != nil && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"github.com/comoyi/steam-server-monitor/client"
+	"testing"
+	"time"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		expr     string
+		want     Condition
+		duration time.Duration
+		wantErr  bool
+	}{
+		{expr: "player_count >= 20 for 5m", want: &compareCondition{field: "player_count", op: ">=", value: 20}, duration: 5 * time.Minute},
+		{expr: "  latency_ms>150.5 ", want: &compareCondition{field: "latency_ms", op: ">", value: 150.5}},
+		{expr: "offline for 2m", want: &statusCondition{status: client.StatusOffline}, duration: 2 * time.Minute},
+		{expr: "online", want: &statusCondition{status: client.StatusOnline}},
+		{expr: `player "X" joined`, want: &playerCondition{name: "X", joined: true}},
+		{expr: `player "A B" left`, want: &playerCondition{name: "A B", joined: false}},
+		{expr: "", wantErr: true},
+		{expr: "offline for", wantErr: true},
+		{expr: "offline for 2x", wantErr: true},
+		{expr: "offline for -2m", wantErr: true},
+		{expr: "players >= 20", wantErr: true},
+		{expr: "player_count => 20", wantErr: true},
+		{expr: "player_count >= many", wantErr: true},
+		{expr: `player "X" joined for 1m`, wantErr: true},
+		{expr: `player X joined`, wantErr: true},
+	}
+	for _, tt := range tests {
+		cond, duration, err := ParseCondition(tt.expr)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseCondition(%q) expected error, got %#v", tt.expr, cond)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseCondition(%q) unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if !sameCondition(cond, tt.want) {
+			t.Errorf("ParseCondition(%q) = %#v, want %#v", tt.expr, cond, tt.want)
+		}
+		if duration != tt.duration {
+			t.Errorf("ParseCondition(%q) duration = %v, want %v", tt.expr, duration, tt.duration)
+		}
+	}
+}
+
+func sameCondition(a Condition, b Condition) bool {
+	switch a := a.(type) {
+	case *compareCondition:
+		b, ok := b.(*compareCondition)
+		return ok && *a == *b
+	case *statusCondition:
+		b, ok := b.(*statusCondition)
+		return ok && *a == *b
+	case *playerCondition:
+		b, ok := b.(*playerCondition)
+		return ok && *a == *b
+	}
+	return false
+}
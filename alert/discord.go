@@ -0,0 +1,122 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// discord allows at most 10 embeds per message
+const discordMaxEmbeds = 10
+
+var discordColors = map[client.Status]int{
+	client.StatusUnknown:  0x9e9e9e,
+	client.StatusOnline:   0x4caf50,
+	client.StatusOffline:  0xf44336,
+	client.StatusDegraded: 0xff9800,
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbed struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Color       int             `json:"color"`
+	Fields      []*discordField `json:"fields"`
+	Timestamp   string          `json:"timestamp"`
+}
+
+type discordMessage struct {
+	Content string          `json:"content"`
+	Embeds  []*discordEmbed `json:"embeds"`
+}
+
+// DiscordTarget posts embeds through a webhook, messages are edited through the webhook message endpoint.
+type DiscordTarget struct {
+	webhook    string
+	httpClient *http.Client
+}
+
+func (t *DiscordTarget) build(msg *Message) *discordMessage {
+	content := fmt.Sprintf("**%s**", msg.Title)
+	if msg.Text != "" {
+		content = fmt.Sprintf("%s\n%s", content, msg.Text)
+	}
+	m := &discordMessage{
+		Content: content,
+		Embeds:  make([]*discordEmbed, 0, len(msg.Servers)),
+	}
+	servers := msg.Servers
+	if len(servers) > discordMaxEmbeds {
+		log.Warnf("discord message shows only the first %d of %d servers\n", discordMaxEmbeds, len(servers))
+		servers = servers[:discordMaxEmbeds]
+	}
+	now := time.Now().Format(time.RFC3339)
+	for _, s := range servers {
+		mapName := s.Map
+		if mapName == "" {
+			mapName = "-"
+		}
+		m.Embeds = append(m.Embeds, &discordEmbed{
+			Title:       s.Name,
+			Description: s.Address,
+			Color:       discordColors[s.Status],
+			Fields: []*discordField{
+				{Name: "状态", Value: statusTexts[s.Status], Inline: true},
+				{Name: "地图", Value: mapName, Inline: true},
+				{Name: "在线人数", Value: s.playerCount(), Inline: true},
+				{Name: "玩家", Value: s.playerList()},
+			},
+			Timestamp: now,
+		})
+	}
+	return m
+}
+
+func (t *DiscordTarget) Post(msg *Message) (string, error) {
+	body, err := json.Marshal(t.build(msg))
+	if err != nil {
+		return "", err
+	}
+	// wait makes discord return the created message
+	url := t.webhook
+	if strings.Contains(url, "?") {
+		url += "&wait=true"
+	} else {
+		url += "?wait=true"
+	}
+	resp := &struct {
+		Id string `json:"id"`
+	}{}
+	err = doJSON(t.httpClient, http.MethodPost, url, nil, body, resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Id, nil
+}
+
+func (t *DiscordTarget) Edit(id string, msg *Message) error {
+	body, err := json.Marshal(t.build(msg))
+	if err != nil {
+		return err
+	}
+	url := t.webhook
+	query := ""
+	if i := strings.Index(url, "?"); i >= 0 {
+		url, query = url[:i], url[i:]
+	}
+	url = fmt.Sprintf("%s/messages/%s%s", strings.TrimSuffix(url, "/"), id, query)
+	return doJSON(t.httpClient, http.MethodPatch, url, nil, body, nil)
+}
+
+func (t *DiscordTarget) CanEdit() bool {
+	return true
+}
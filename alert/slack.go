@@ -0,0 +1,155 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"strings"
+)
+
+var slackApiUrl = "https://slack.com/api"
+
+// slack allows at most 50 blocks per message
+const slackMaxBlocks = 50
+
+// slack allows at most 150 characters in a header and rejects an empty one
+const slackMaxHeaderLength = 150
+
+const slackDefaultTitle = "服务器监控"
+
+var slackStatusEmojis = map[client.Status]string{
+	client.StatusUnknown:  ":white_circle:",
+	client.StatusOnline:   ":large_green_circle:",
+	client.StatusOffline:  ":red_circle:",
+	client.StatusDegraded: ":large_orange_circle:",
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type   string       `json:"type"`
+	Text   *slackText   `json:"text,omitempty"`
+	Fields []*slackText `json:"fields,omitempty"`
+}
+
+type slackMessage struct {
+	Channel string        `json:"channel,omitempty"`
+	Ts      string        `json:"ts,omitempty"`
+	Text    string        `json:"text"`
+	Blocks  []*slackBlock `json:"blocks"`
+}
+
+type slackResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+	Ts    string `json:"ts"`
+}
+
+// SlackTarget posts blocks through an incoming webhook, or through the web api with a bot token
+// which is required to edit messages.
+type SlackTarget struct {
+	webhook    string
+	token      string
+	channel    string
+	httpClient *http.Client
+}
+
+func (t *SlackTarget) build(msg *Message) *slackMessage {
+	title := strings.TrimSpace(msg.Title)
+	if title == "" {
+		title = slackDefaultTitle
+	}
+	m := &slackMessage{
+		Text:   title,
+		Blocks: make([]*slackBlock, 0),
+	}
+	if header := []rune(title); len(header) > slackMaxHeaderLength {
+		title = string(header[:slackMaxHeaderLength-1]) + "…"
+	}
+	m.Blocks = append(m.Blocks, &slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: title}})
+	if msg.Text != "" {
+		m.Blocks = append(m.Blocks, &slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: slackEscape(msg.Text)}})
+	}
+	for i, s := range msg.Servers {
+		// each server takes a divider, a section and the player list
+		if len(m.Blocks)+3 > slackMaxBlocks {
+			log.Warnf("slack message shows only the first %d of %d servers\n", i, len(msg.Servers))
+			break
+		}
+		mapName := s.Map
+		if mapName == "" {
+			mapName = "-"
+		}
+		m.Blocks = append(m.Blocks, &slackBlock{Type: "divider"})
+		m.Blocks = append(m.Blocks, &slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("%s *%s*\n%s", slackStatusEmojis[s.Status], slackEscape(s.Name), s.Address)},
+			Fields: []*slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("*状态*\n%s", statusTexts[s.Status])},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*地图*\n%s", slackEscape(mapName))},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*在线人数*\n%s", s.playerCount())},
+			},
+		})
+		m.Blocks = append(m.Blocks, &slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("*玩家*\n%s", slackEscape(s.playerList()))},
+		})
+	}
+	return m
+}
+
+func (t *SlackTarget) Post(msg *Message) (string, error) {
+	m := t.build(msg)
+	if !t.CanEdit() {
+		body, err := json.Marshal(m)
+		if err != nil {
+			return "", err
+		}
+		return "", doJSON(t.httpClient, http.MethodPost, t.webhook, nil, body, nil)
+	}
+	m.Channel = t.channel
+	return t.call("chat.postMessage", m)
+}
+
+func (t *SlackTarget) Edit(id string, msg *Message) error {
+	m := t.build(msg)
+	m.Channel = t.channel
+	m.Ts = id
+	_, err := t.call("chat.update", m)
+	return err
+}
+
+func (t *SlackTarget) CanEdit() bool {
+	return t.token != "" && t.channel != ""
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackEscape escapes the control characters of mrkdwn.
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// call invokes a web api method, slack reports errors in the body with status 200.
+func (t *SlackTarget) call(method string, m *slackMessage) (string, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+t.token)
+	resp := &slackResponse{}
+	err = doJSON(t.httpClient, http.MethodPost, fmt.Sprintf("%s/%s", slackApiUrl, method), header, body, resp)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Ok {
+		return "", fmt.Errorf("slack %s failed: %s", method, resp.Error)
+	}
+	return resp.Ts, nil
+}
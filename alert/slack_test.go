@@ -0,0 +1,33 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSlackHeader(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "服务器离线", want: "服务器离线"},
+		{title: "", want: slackDefaultTitle},
+		{title: "  ", want: slackDefaultTitle},
+		{title: strings.Repeat("离", 150), want: strings.Repeat("离", 150)},
+		{title: strings.Repeat("离", 151), want: strings.Repeat("离", 149) + "…"},
+	}
+	for _, tt := range tests {
+		m := (&SlackTarget{}).build(&Message{Title: tt.title})
+		header := m.Blocks[0]
+		if header.Type != "header" || header.Text.Text != tt.want {
+			t.Errorf("header of %q = %q, want %q", tt.title, header.Text.Text, tt.want)
+		}
+		if n := utf8.RuneCountInString(header.Text.Text); n > slackMaxHeaderLength {
+			t.Errorf("header of %q has %d characters", tt.title, n)
+		}
+		if m.Text == "" {
+			t.Errorf("text of %q is empty", tt.title)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package alert
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// max number of player names listed for a server
+const maxListedPlayers = 20
+
+// Message is the content posted to a target, rendered as a Discord embed or Slack blocks.
+type Message struct {
+	Title   string
+	Text    string
+	Servers []*ServerSummary
+}
+
+type ServerSummary struct {
+	Name        string
+	Address     string
+	Map         string
+	Status      client.Status
+	PlayerCount int64
+	MaxPlayers  int64
+	Players     []string
+}
+
+func newServerSummary(server *client.Server) *ServerSummary {
+	conf := server.Config()
+	state := server.State()
+	summary := &ServerSummary{
+		Name:    conf.DisplayName,
+		Address: conf.Address(),
+		Status:  state.Status,
+		Players: make([]string, 0),
+	}
+	if state.Info != nil {
+		if summary.Name == "" {
+			summary.Name = state.Info.ServerName
+		}
+		summary.Map = state.Info.Map
+		summary.PlayerCount = state.Info.PlayerCount
+		summary.MaxPlayers = state.Info.MaxPlayers
+	}
+	// the players of the last result are gone when the server is offline
+	if state.Info != nil && state.Status != client.StatusOffline {
+		for _, p := range state.Info.Players {
+			if p != nil && p.Name != "" {
+				summary.Players = append(summary.Players, p.Name)
+			}
+		}
+	}
+	if summary.Name == "" {
+		summary.Name = summary.Address
+	}
+	return summary
+}
+
+func (s *ServerSummary) playerList() string {
+	if len(s.Players) == 0 {
+		return "-"
+	}
+	text := ""
+	for i, name := range s.Players {
+		if i == maxListedPlayers {
+			text += fmt.Sprintf("\n... 共 %d 人", len(s.Players))
+			break
+		}
+		if i > 0 {
+			text += "\n"
+		}
+		text += name
+	}
+	return text
+}
+
+func (s *ServerSummary) playerCount() string {
+	if s.Status == client.StatusOffline || s.Status == client.StatusUnknown {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d", s.PlayerCount, s.MaxPlayers)
+}
+
+var statusTexts = map[client.Status]string{
+	client.StatusUnknown:  "未知",
+	client.StatusOnline:   "在线",
+	client.StatusOffline:  "离线",
+	client.StatusDegraded: "异常",
+}
+
+// Target posts messages and edits them in place when supported.
+type Target interface {
+	// Post sends a new message and returns its id, empty when the message can not be edited.
+	Post(msg *Message) (string, error)
+	Edit(id string, msg *Message) error
+	CanEdit() bool
+}
+
+func NewTarget(t *config.Target, httpClient *http.Client) (Target, error) {
+	switch t.Type {
+	case "discord":
+		if t.Webhook == "" {
+			return nil, fmt.Errorf("webhook is required")
+		}
+		return &DiscordTarget{webhook: t.Webhook, httpClient: httpClient}, nil
+	case "slack":
+		if t.Webhook == "" && (t.Token == "" || t.Channel == "") {
+			return nil, fmt.Errorf("webhook or token and channel is required")
+		}
+		return &SlackTarget{webhook: t.Webhook, token: t.Token, channel: t.Channel, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", t.Type)
+	}
+}
+
+// targetEntry is a configured target with the state of its events and live message.
+type targetEntry struct {
+	conf   *config.Target
+	target Target
+
+	statuses map[*client.Server]client.Status
+	liveId   string
+	dirty    bool
+	mu       sync.Mutex
+}
+
+func (t *targetEntry) matchServer(conf client.ServerConfig) bool {
+	if len(t.conf.Servers) == 0 {
+		return true
+	}
+	for _, s := range t.conf.Servers {
+		if s == conf.Id || s == conf.Address() || s == conf.DisplayName {
+			return true
+		}
+	}
+	return false
+}
+
+var eventTitles = map[client.Status]string{
+	client.StatusOnline:   "服务器恢复在线",
+	client.StatusOffline:  "服务器离线",
+	client.StatusDegraded: "服务器状态异常",
+}
+
+// onRefresh posts the status changes and marks the live message outdated.
+func (t *targetEntry) onRefresh(server *client.Server) {
+	if !t.matchServer(server.Config()) {
+		return
+	}
+	state := server.State()
+	t.mu.Lock()
+	previous, seen := t.statuses[server]
+	t.statuses[server] = state.Status
+	t.dirty = true
+	t.mu.Unlock()
+
+	// the first result is not a change
+	if !t.conf.Events || !seen || previous == state.Status {
+		return
+	}
+	msg := &Message{
+		Title:   eventTitles[state.Status],
+		Servers: []*ServerSummary{newServerSummary(server)},
+	}
+	if state.Status != client.StatusOnline {
+		msg.Text = state.LastError
+	}
+	go func() {
+		_, err := withRetry(func() error {
+			_, err := t.target.Post(msg)
+			return err
+		})
+		if err != nil {
+			log.Warnf("post status change to target %s failed, err: %v\n", t.conf.Name, err)
+		}
+	}()
+}
+
+// runLive updates the live message at most once per interval while the status changed.
+func (t *targetEntry) runLive() {
+	interval := time.Duration(t.conf.LiveInterval) * time.Second
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+	for {
+		time.Sleep(interval)
+		t.mu.Lock()
+		dirty := t.dirty
+		t.dirty = false
+		t.mu.Unlock()
+		if dirty {
+			t.updateLive()
+		}
+	}
+}
+
+func (t *targetEntry) updateLive() {
+	msg := &Message{
+		Title:   "服务器状态",
+		Text:    fmt.Sprintf("更新于 %s", time.Now().Format("2006-01-02 15:04:05")),
+		Servers: make([]*ServerSummary, 0),
+	}
+	for _, server := range client.GetServerContainer().GetServers() {
+		if t.matchServer(server.Config()) {
+			msg.Servers = append(msg.Servers, newServerSummary(server))
+		}
+	}
+
+	if t.liveId != "" {
+		err := t.target.Edit(t.liveId, msg)
+		if err == nil {
+			return
+		}
+		// the message may have been deleted, post a new one
+		log.Warnf("edit live message of target %s failed, err: %v\n", t.conf.Name, err)
+	}
+	id, err := t.target.Post(msg)
+	if err != nil {
+		log.Warnf("post live message to target %s failed, err: %v\n", t.conf.Name, err)
+		return
+	}
+	t.liveId = id
+}
+
+var targets = make(map[string]*targetEntry)
+
+// initTargets creates the configured targets and starts their events and live messages.
+func initTargets(httpClient *http.Client) {
+	for _, t := range config.Conf.Targets {
+		target, err := NewTarget(t, httpClient)
+		if err != nil {
+			log.Errorf("invalid target %q, err: %v\n", t.Name, err)
+			continue
+		}
+		entry := &targetEntry{
+			conf:     t,
+			target:   target,
+			statuses: make(map[*client.Server]client.Status),
+		}
+		targets[t.Name] = entry
+		if !t.Events && !t.Live {
+			continue
+		}
+		client.GetServerContainer().AddListener(entry.onRefresh)
+		if t.Live {
+			if !target.CanEdit() {
+				log.Warnf("target %s can not edit messages, live status message disabled\n", t.Name)
+				continue
+			}
+			go entry.runLive()
+		}
+	}
+}
+
+// sendAlert delivers an alert to the named target.
+func sendAlert(name string, alert *Alert) (int64, error) {
+	entry, ok := targets[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown target %q", name)
+	}
+	msg := &Message{
+		Title: alert.Rule,
+		Text:  alert.Message,
+		Servers: []*ServerSummary{
+			{
+				Name:        alert.Server,
+				Address:     alert.Address,
+				Map:         alert.Map,
+				Status:      alert.Status,
+				PlayerCount: alert.PlayerCount,
+				MaxPlayers:  alert.MaxPlayers,
+				Players:     alert.Players,
+			},
+		},
+	}
+	return withRetry(func() error {
+		_, err := entry.target.Post(msg)
+		return err
+	})
+}
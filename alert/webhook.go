@@ -0,0 +1,129 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+)
+
+// Sender delivers an alert and returns the number of attempts.
+type Sender interface {
+	Send(rule *Rule, alert *Alert) (int64, error)
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookSender posts the alert as JSON, or to the target of the rule when set.
+type WebhookSender struct {
+	httpClient *http.Client
+}
+
+func NewWebhookSender(httpClient *http.Client) *WebhookSender {
+	return &WebhookSender{
+		httpClient: httpClient,
+	}
+}
+
+func (s *WebhookSender) Send(rule *Rule, alert *Alert) (int64, error) {
+	if rule.Target != "" {
+		return sendAlert(rule.Target, alert)
+	}
+	body, err := renderBody(rule, alert)
+	if err != nil {
+		return 0, err
+	}
+	return withRetry(func() error {
+		return doJSON(s.httpClient, http.MethodPost, rule.Webhook, nil, body, nil)
+	})
+}
+
+// withRetry calls fn until it succeeds or the retries are used up, the backoff doubles on each retry.
+func withRetry(fn func() error) (int64, error) {
+	var attempts int64 = 0
+	backoff := time.Duration(config.Conf.AlertRetryBackoffMs) * time.Millisecond
+	for {
+		attempts++
+		err := fn()
+		if err == nil || attempts > config.Conf.AlertRetries || !retryable(err) {
+			return attempts, err
+		}
+		log.Debugf("request failed, retry in %v, err: %v\n", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// statusError is a response with a status other than 2xx.
+type statusError struct {
+	status string
+	code   int
+	body   []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s, body: %s", e.status, e.body)
+}
+
+// retryable reports whether a retry can succeed, a client error fails again except for a rate limit.
+func retryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.code < 400 || se.code >= 500 || se.code == http.StatusTooManyRequests
+}
+
+// doJSON sends body and decodes the response into out when out is not nil.
+func doJSON(httpClient *http.Client, method string, url string, header http.Header, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{status: resp.Status, code: resp.StatusCode, body: data}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func renderBody(rule *Rule, alert *Alert) ([]byte, error) {
+	if rule.BodyTmpl == nil {
+		return json.Marshal(alert)
+	}
+	buf := &bytes.Buffer{}
+	err := rule.BodyTmpl.Execute(buf, alert)
+	if err != nil {
+		return nil, fmt.Errorf("execute body template failed: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("body template does not render valid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"encoding/json"
+	"github.com/comoyi/steam-server-monitor/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// webhookServer answers the requests with statuses in turn, the last one is repeated.
+type webhookServer struct {
+	*httptest.Server
+	statuses []int
+	bodies   [][]byte
+	mu       sync.Mutex
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			t.Errorf("read body failed, err: %v", err)
+		}
+		if contentType := request.Header.Get("Content-Type"); contentType != "application/json; charset=UTF-8" {
+			t.Errorf("Content-Type = %q", contentType)
+		}
+		s.mu.Lock()
+		s.bodies = append(s.bodies, body)
+		status := s.statuses[len(s.statuses)-1]
+		if len(s.bodies) <= len(s.statuses) {
+			status = s.statuses[len(s.bodies)-1]
+		}
+		s.mu.Unlock()
+		writer.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies
+}
+
+func setRetries(t *testing.T, retries int64) {
+	logToTempDir(t)
+	old := config.Conf
+	config.Conf.AlertRetries = retries
+	config.Conf.AlertRetryBackoffMs = 1
+	t.Cleanup(func() {
+		config.Conf = old
+	})
+}
+
+func newTestRule(t *testing.T, webhook string, body string) *Rule {
+	rule, err := NewRule(&config.Alert{Name: "test", Condition: "offline", Webhook: webhook, Body: body})
+	if err != nil {
+		t.Fatalf("NewRule failed, err: %v", err)
+	}
+	return rule
+}
+
+func TestWebhookSenderDelivery(t *testing.T) {
+	setRetries(t, 2)
+	server := newWebhookServer(t, http.StatusOK)
+	alert := &Alert{Rule: "test", Address: "127.0.0.1:27015", Message: "server is offline", Players: []string{}}
+
+	attempts, err := NewWebhookSender(server.Client()).Send(newTestRule(t, server.URL, ""), alert)
+	if err != nil {
+		t.Fatalf("Send failed, err: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	requests := server.requests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	got := &Alert{}
+	if err := json.Unmarshal(requests[0], got); err != nil {
+		t.Fatalf("decode body failed, err: %v", err)
+	}
+	if got.Address != alert.Address || got.Message != alert.Message {
+		t.Errorf("body = %s", requests[0])
+	}
+}
+
+func TestWebhookSenderRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int64
+		wantErr  bool
+	}{
+		{name: "retry on 5xx", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, attempts: 3},
+		{name: "retries used up", statuses: []int{http.StatusServiceUnavailable}, attempts: 3, wantErr: true},
+		{name: "stop on 4xx", statuses: []int{http.StatusBadRequest}, attempts: 1, wantErr: true},
+		{name: "retry on 429", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, attempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRetries(t, 2)
+			server := newWebhookServer(t, tt.statuses...)
+			attempts, err := NewWebhookSender(server.Client()).Send(newTestRule(t, server.URL, ""), &Alert{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			if n := int64(len(server.requests())); n != tt.attempts {
+				t.Errorf("got %d requests, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestWebhookSenderBodyTemplate(t *testing.T) {
+	setRetries(t, 0)
+	server := newWebhookServer(t, http.StatusOK)
+	rule := newTestRule(t, server.URL, `{"text": {{json .Message}}, "server": "{{.Server}}", "players": {{json .Players}}}`)
+	alert := &Alert{Server: "my server", Message: `player "X" joined`, Players: []string{"X", "Y"}}
+
+	if _, err := NewWebhookSender(server.Client()).Send(rule, alert); err != nil {
+		t.Fatalf("Send failed, err: %v", err)
+	}
+	got := struct {
+		Text    string   `json:"text"`
+		Server  string   `json:"server"`
+		Players []string `json:"players"`
+	}{}
+	if err := json.Unmarshal(server.requests()[0], &got); err != nil {
+		t.Fatalf("decode body failed, err: %v", err)
+	}
+	if got.Text != alert.Message || got.Server != alert.Server || len(got.Players) != 2 {
+		t.Errorf("body = %s", server.requests()[0])
+	}
+}
+
+func TestWebhookSenderInvalidBody(t *testing.T) {
+	setRetries(t, 2)
+	server := newWebhookServer(t, http.StatusOK)
+	// the message is not quoted by json
+	rule := newTestRule(t, server.URL, `{"text": "{{.Message}}"}`)
+
+	attempts, err := NewWebhookSender(server.Client()).Send(rule, &Alert{Message: `player "X" joined`})
+	if err == nil {
+		t.Fatalf("Send expected error")
+	}
+	if attempts != 0 || len(server.requests()) != 0 {
+		t.Errorf("attempts = %d, requests = %d, want none", attempts, len(server.requests()))
+	}
+}
@@ -1,56 +1,261 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"github.com/comoyi/steam-server-monitor/alert"
 	"github.com/comoyi/steam-server-monitor/client"
 	"github.com/comoyi/steam-server-monitor/config"
 	"github.com/comoyi/steam-server-monitor/log"
+	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 )
 
-func Start() {
+const shutdownTimeout = 5 * time.Second
 
-	http.HandleFunc("/api/v1/info", info)
-	err := http.ListenAndServe(fmt.Sprintf(":%d", config.Conf.ApiPort), nil)
-	if err != nil {
-		fmt.Printf("server start failed err: %v\n", err)
-		log.Errorf("server start failed err: %v\n", err)
-		return
+// settings are the config the listener is started with, a change of them restarts the server.
+type settings struct {
+	addr         string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	tlsCert      string
+	tlsKey       string
+}
+
+func currentSettings() settings {
+	api := config.GetApiSettings()
+	return settings{
+		addr:         net.JoinHostPort(api.Bind, strconv.FormatInt(api.Port, 10)),
+		readTimeout:  time.Duration(config.Conf.ApiReadTimeout) * time.Second,
+		writeTimeout: time.Duration(config.Conf.ApiWriteTimeout) * time.Second,
+		idleTimeout:  time.Duration(config.Conf.ApiIdleTimeout) * time.Second,
+		tlsCert:      config.Conf.ApiTlsCert,
+		tlsKey:       config.Conf.ApiTlsKey,
 	}
 }
 
-func info(writer http.ResponseWriter, request *http.Request) {
-	var err error
-	host := request.URL.Query().Get("host")
-	portRaw, _ := strconv.Atoi(request.URL.Query().Get("port"))
-	var port int64 = int64(portRaw)
-	serverContainer := client.GetServerContainer()
-	servers := serverContainer.GetServers()
-	var server *client.Server
-	for _, s := range servers {
-		if s.Ip == host && s.Port == port {
-			server = s
-			break
+// Server is the http server of the api and the dashboard, it can be started again after Shutdown.
+type Server struct {
+	httpServer *http.Server
+	settings   settings
+	mu         sync.Mutex
+}
+
+func NewServer() *Server {
+	return &Server{}
+}
+
+func (s *Server) newHandler(writeTimeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler http.HandlerFunc) {
+		h := http.Handler(authorize(handler))
+		if writeTimeout > 0 {
+			h = timeoutHandler(h, writeTimeout)
+		}
+		mux.Handle(pattern, h)
+	}
+	handle("/api/v1/info", info)
+	handle("/api/v1/servers", servers)
+	handle("/api/v2/servers", serversV2)
+	handle("/api/v2/servers/", serverV2)
+	handle("/api/v1/players", players)
+	handle("/api/v1/alerts", alerts)
+	handle("/api/v1/query", query)
+	handle("/metrics", metrics)
+	handle("/api/", notFound)
+	// the stream is long lived, it ends with the client or the server
+	mux.HandleFunc(streamPath, authorize(stream))
+	// the dashboard itself has no data, it asks for a token when the api requires one
+	mux.Handle("/", web())
+	return mux
+}
+
+// timeoutHandler is http.TimeoutHandler with a JSON body, the content type is replaced by the one
+// of the handler when it finishes in time.
+func timeoutHandler(handler http.Handler, timeout time.Duration) http.Handler {
+	h := http.TimeoutHandler(handler, timeout, `{"error":{"code":"timeout","message":"request timeout"}}`)
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		h.ServeHTTP(writer, request)
+	})
+}
+
+// Start listens and serves in the background, the server is shut down when ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.start(ctx, currentSettings())
+}
+
+func (s *Server) start(ctx context.Context, st settings) error {
+	if s.httpServer != nil {
+		return fmt.Errorf("server is already started")
+	}
+	initAuth()
+	initQuery()
+	initStream()
+
+	var tlsConfig *tls.Config
+	if st.tlsCert != "" && st.tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(st.tlsCert, st.tlsKey)
+		if err != nil {
+			return err
 		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	listener, err := net.Listen("tcp", st.addr)
+	if err != nil {
+		return err
 	}
 
-	bytes := []byte("")
-	if server != nil {
-		bytes, err = json.Marshal(server.Info)
+	// canceled on shutdown to end the streams, which would keep the shutdown waiting otherwise
+	baseCtx, cancel := context.WithCancel(ctx)
+	httpServer := &http.Server{
+		Handler:           s.newHandler(st.writeTimeout),
+		ReadHeaderTimeout: st.readTimeout,
+		ReadTimeout:       st.readTimeout,
+		IdleTimeout:       st.idleTimeout,
+		TLSConfig:         tlsConfig,
+		BaseContext: func(net.Listener) context.Context {
+			return baseCtx
+		},
+	}
+	httpServer.RegisterOnShutdown(cancel)
+	s.httpServer = httpServer
+	s.settings = st
+
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ServeTLS(listener, "", "")
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		cancel()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("server stopped, err: %v\n", err)
+		}
+	}()
+	go func() {
+		<-baseCtx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.httpServer != httpServer {
+			return
+		}
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+		_ = s.shutdown(shutdownCtx)
+	}()
+	log.Infof("api listen on %s\n", st.addr)
+	return nil
+}
+
+// Shutdown stops the server gracefully, the requests in progress have until ctx is done to finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown(ctx)
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
 	}
+	httpServer := s.httpServer
+	s.httpServer = nil
+	err := httpServer.Shutdown(ctx)
 	if err != nil {
-		log.Debugf("json.Marshal failed, err: %s\n", err)
+		log.Warnf("shutdown server failed, err: %v\n", err)
+		_ = httpServer.Close()
+	}
+	return err
+}
+
+// Apply starts, restarts or stops the server to match enable and the current config.
+func (s *Server) Apply(ctx context.Context, enable bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := currentSettings()
+	if s.httpServer != nil {
+		if enable && s.settings == st {
+			return nil
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = s.shutdown(shutdownCtx)
+	}
+	if !enable {
+		return nil
+	}
+	return s.start(ctx, st)
+}
+
+type infoResponse struct {
+	*client.Info
+	Status          client.Status `json:"status"`
+	LastSuccessTime int64         `json:"last_success_time"`
+	LastError       string        `json:"last_error"`
+	FailureCount    int64         `json:"failure_count"`
+}
+
+func newInfoResponse(server *client.Server) *infoResponse {
+	state := server.State()
+	return &infoResponse{
+		Info:            state.Info,
+		Status:          state.Status,
+		LastSuccessTime: unixTime(state.LastSuccessTime),
+		LastError:       state.LastError,
+		FailureCount:    state.FailureCount,
+	}
+}
+
+func info(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	server, ok := findServer(writer, request)
+	if !ok {
 		return
 	}
+	if server.State().Info == nil {
+		writer.Header().Set("Retry-After", strconv.FormatInt(server.Config().Interval, 10))
+		writeError(writer, http.StatusServiceUnavailable, codeNoData, "no data collected yet")
+		return
+	}
+	writeJSON(writer, http.StatusOK, newInfoResponse(server))
+}
 
-	j := string(bytes)
-	log.Debugf("json: %s\n", j)
-	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	_, err = writer.Write(bytes)
+func players(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	name := request.URL.Query().Get("name")
+	stats, err := client.GetPlayerStats(name)
 	if err != nil {
-		log.Debugf("write failed, err: %s\n", err)
+		log.Warnf("GetPlayerStats failed, err: %v\n", err)
+		writeError(writer, http.StatusInternalServerError, codeInternalError, "read player stats failed")
 		return
 	}
+	writeJSON(writer, http.StatusOK, stats)
+}
+
+func alerts(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	records := make([]*alert.Record, 0)
+	if engine := alert.GetEngine(); engine != nil {
+		records = engine.Records()
+	}
+	writeJSON(writer, http.StatusOK, records)
 }
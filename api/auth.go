@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	scopeRead  = "read"
+	scopeAdmin = "admin"
+)
+
+// rateLimiter is a bucket holding up to limit requests which refills in a minute.
+type rateLimiter struct {
+	// max requests per minute, 0 means unlimited
+	limit int64
+
+	available float64
+	lastTime  time.Time
+	mu        sync.Mutex
+}
+
+func newRateLimiter(limit int64) *rateLimiter {
+	return &rateLimiter{limit: limit}
+}
+
+// allow takes a request from the bucket, it returns how long to wait when the bucket is empty.
+func (l *rateLimiter) allow() (bool, time.Duration) {
+	return l.allowAt(time.Now())
+}
+
+func (l *rateLimiter) allowAt(now time.Time) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+	limit := float64(l.limit)
+	perSecond := limit / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lastTime.IsZero() {
+		l.available = limit
+	} else {
+		l.available = math.Min(limit, l.available+now.Sub(l.lastTime).Seconds()*perSecond)
+	}
+	l.lastTime = now
+	if l.available < 1 {
+		return false, time.Duration((1 - l.available) / perSecond * float64(time.Second))
+	}
+	l.available--
+	return true, 0
+}
+
+// idle reports whether the bucket has refilled, such a limiter is the same as a new one.
+func (l *rateLimiter) idle(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return now.Sub(l.lastTime) >= time.Minute
+}
+
+// rateLimiterGroup is a rateLimiter for each key, the idle ones are dropped once a minute.
+type rateLimiterGroup struct {
+	limit       int64
+	limiters    map[string]*rateLimiter
+	lastCleanup time.Time
+	mu          sync.Mutex
+}
+
+func newRateLimiterGroup(limit int64) *rateLimiterGroup {
+	return &rateLimiterGroup{
+		limit:       limit,
+		limiters:    make(map[string]*rateLimiter),
+		lastCleanup: time.Now(),
+	}
+}
+
+func (g *rateLimiterGroup) get(key string) *rateLimiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if now.Sub(g.lastCleanup) >= time.Minute {
+		for k, l := range g.limiters {
+			if l.idle(now) {
+				delete(g.limiters, k)
+			}
+		}
+		g.lastCleanup = now
+	}
+	l, ok := g.limiters[key]
+	if !ok {
+		l = newRateLimiter(g.limit)
+		g.limiters[key] = l
+	}
+	return l
+}
+
+// writeRateLimited takes a request from limiter, the error is written when the limit is exceeded.
+func writeRateLimited(writer http.ResponseWriter, limiter *rateLimiter) bool {
+	ok, wait := limiter.allow()
+	if ok {
+		return false
+	}
+	writer.Header().Set("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(wait.Seconds()))))
+	writeError(writer, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+	return true
+}
+
+// apiToken is a configured token with its rate limit.
+type apiToken struct {
+	conf    *config.ApiToken
+	limiter *rateLimiter
+}
+
+var apiTokens []*apiToken
+
+func initAuth() {
+	apiTokens = make([]*apiToken, 0)
+	for _, t := range config.Conf.ApiTokens {
+		if t.Token == "" {
+			log.Errorf("invalid api token %q, err: token is required\n", t.Name)
+			continue
+		}
+		if t.Scope != scopeRead && t.Scope != scopeAdmin {
+			log.Errorf("invalid api token %q, err: unknown scope %q\n", t.Name, t.Scope)
+			continue
+		}
+		apiTokens = append(apiTokens, &apiToken{conf: t, limiter: newRateLimiter(t.RateLimit)})
+	}
+	if len(apiTokens) == 0 {
+		log.Warnf("no api token configured, the api is open to everyone who can reach it\n")
+	}
+}
+
+// requestToken reads the bearer token, the token parameter is accepted by the stream only as EventSource
+// can not set headers, elsewhere it would leak the token into logs and the browser history.
+func requestToken(request *http.Request) string {
+	if authorization := request.Header.Get("Authorization"); authorization != "" {
+		if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+			return strings.TrimSpace(authorization[7:])
+		}
+		return ""
+	}
+	if request.URL.Path != streamPath {
+		return ""
+	}
+	return request.URL.Query().Get("token")
+}
+
+func findToken(token string) *apiToken {
+	if token == "" {
+		return nil
+	}
+	for _, t := range apiTokens {
+		if subtle.ConstantTimeCompare([]byte(t.conf.Token), []byte(token)) == 1 {
+			return t
+		}
+	}
+	return nil
+}
+
+// authorize requires a token for the handler when tokens are configured,
+// reads need the read or admin scope and modifications need the admin scope.
+func authorize(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if len(apiTokens) == 0 {
+			handler(writer, request)
+			return
+		}
+		token := findToken(requestToken(request))
+		if token == nil {
+			writer.Header().Set("WWW-Authenticate", `Bearer realm="steam-server-monitor"`)
+			writeError(writer, http.StatusUnauthorized, codeUnauthorized, "invalid or missing token")
+			return
+		}
+		if request.Method != http.MethodGet && request.Method != http.MethodHead && token.conf.Scope != scopeAdmin {
+			writeError(writer, http.StatusForbidden, codeForbidden, "admin scope required")
+			return
+		}
+		if writeRateLimited(writer, token.limiter) {
+			return
+		}
+		handler(writer, request)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type step struct {
+		// seconds since the first request
+		at   float64
+		ok   bool
+		wait time.Duration
+	}
+	tests := []struct {
+		name  string
+		limit int64
+		steps []step
+	}{
+		{
+			name:  "unlimited",
+			limit: 0,
+			steps: []step{{at: 0, ok: true}, {at: 0, ok: true}, {at: 0, ok: true}, {at: 0, ok: true}},
+		},
+		{
+			name:  "burst up to the limit",
+			limit: 3,
+			steps: []step{{at: 0, ok: true}, {at: 0, ok: true}, {at: 0, ok: true}, {at: 0, ok: false, wait: 20 * time.Second}},
+		},
+		{
+			name:  "refills over a minute",
+			limit: 3,
+			steps: []step{
+				{at: 0, ok: true}, {at: 0, ok: true}, {at: 0, ok: true},
+				{at: 5, ok: false, wait: 15 * time.Second},
+				{at: 20, ok: true},
+				{at: 20, ok: false, wait: 20 * time.Second},
+			},
+		},
+		{
+			name:  "refills no more than the limit",
+			limit: 2,
+			steps: []step{
+				{at: 0, ok: true},
+				{at: 600, ok: true}, {at: 600, ok: true},
+				{at: 600, ok: false, wait: 30 * time.Second},
+			},
+		},
+	}
+	start := time.Unix(1700000000, 0)
+	for _, tt := range tests {
+		l := newRateLimiter(tt.limit)
+		for i, step := range tt.steps {
+			ok, wait := l.allowAt(start.Add(time.Duration(step.at * float64(time.Second))))
+			if ok != step.ok {
+				t.Errorf("%s: step %d: ok = %v, want %v", tt.name, i, ok, step.ok)
+			}
+			if diff := wait - step.wait; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("%s: step %d: wait = %v, want %v", tt.name, i, wait, step.wait)
+			}
+		}
+	}
+}
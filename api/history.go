@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"github.com/comoyi/steam-server-monitor/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var historyRanges = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+const defaultHistoryPoints = 240
+
+const maxHistoryPoints = 2000
+
+type historyResponse struct {
+	// unix time in seconds
+	From    int64           `json:"from"`
+	To      int64           `json:"to"`
+	Samples []*store.Sample `json:"samples"`
+}
+
+// history handles /api/v2/servers/{id}/history, range is one of 1h/24h/7d/30d and
+// the range is split into points buckets with at most one sample each.
+func history(writer http.ResponseWriter, request *http.Request, server *client.Server) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	query := request.URL.Query()
+	rangeRaw := query.Get("range")
+	if rangeRaw == "" {
+		rangeRaw = "24h"
+	}
+	duration, ok := historyRanges[rangeRaw]
+	if !ok {
+		writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "range", "range must be one of 1h, 24h, 7d, 30d")
+		return
+	}
+	points := defaultHistoryPoints
+	if pointsRaw := query.Get("points"); pointsRaw != "" {
+		p, err := strconv.Atoi(pointsRaw)
+		if err != nil || p <= 0 || p > maxHistoryPoints {
+			writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "points", fmt.Sprintf("points must be between 1 and %d", maxHistoryPoints))
+			return
+		}
+		points = p
+	}
+
+	to := time.Now().Truncate(time.Second)
+	from := to.Add(-duration)
+	samples, err := client.GetHistoryPoints(server, from, to, points)
+	if err != nil {
+		log.Warnf("GetHistoryPoints failed, err: %v\n", err)
+		writeError(writer, http.StatusInternalServerError, codeInternalError, "read history failed")
+		return
+	}
+	writeJSON(writer, http.StatusOK, &historyResponse{
+		From:    from.Unix(),
+		To:      to.Unix(),
+		Samples: samples,
+	})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var startTime = time.Now()
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricWriter writes metrics in the Prometheus text exposition format.
+type metricWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *metricWriter) header(name string, typ string, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *metricWriter) sample(name string, labels string, value float64) {
+	fmt.Fprintf(&w.buf, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func serverLabels(conf client.ServerConfig) string {
+	return fmt.Sprintf(`{id="%s",name="%s",ip="%s",port="%d"}`, labelEscaper.Replace(conf.Id), labelEscaper.Replace(conf.DisplayName), labelEscaper.Replace(conf.Ip), conf.Port)
+}
+
+type serverMetric struct {
+	name  string
+	typ   string
+	help  string
+	value func(state client.ServerState) (float64, bool)
+}
+
+var serverMetrics = []*serverMetric{
+	{"steam_server_up", "gauge", "Whether the last query of the server succeeded.", func(state client.ServerState) (float64, bool) {
+		if state.Status == client.StatusOnline || state.Status == client.StatusDegraded {
+			return 1, true
+		}
+		return 0, true
+	}},
+	{"steam_server_player_count", "gauge", "Number of players on the server.", func(state client.ServerState) (float64, bool) {
+		if state.Info == nil || state.Status == client.StatusOffline {
+			return 0, false
+		}
+		return float64(state.Info.PlayerCount), true
+	}},
+	{"steam_server_max_players", "gauge", "Max number of players of the server.", func(state client.ServerState) (float64, bool) {
+		if state.Info == nil || state.Status == client.StatusOffline {
+			return 0, false
+		}
+		return float64(state.Info.MaxPlayers), true
+	}},
+	{"steam_server_query_latency_seconds", "gauge", "Round trip time of the last A2S_INFO query.", func(state client.ServerState) (float64, bool) {
+		if state.Info == nil || state.Status == client.StatusOffline {
+			return 0, false
+		}
+		return state.Info.LatencyMs / 1000, true
+	}},
+	{"steam_server_queries_total", "counter", "Number of queries of the server.", func(state client.ServerState) (float64, bool) {
+		return float64(state.QueryCount), true
+	}},
+	{"steam_server_query_failures_total", "counter", "Number of failed queries of the server.", func(state client.ServerState) (float64, bool) {
+		return float64(state.QueryFailures), true
+	}},
+	{"steam_server_last_success_timestamp_seconds", "gauge", "Unix time of the last successful query of the server.", func(state client.ServerState) (float64, bool) {
+		if state.LastSuccessTime.IsZero() {
+			return 0, false
+		}
+		return float64(state.LastSuccessTime.UnixNano()) / 1e9, true
+	}},
+}
+
+func metrics(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	w := &metricWriter{}
+
+	servers := client.GetServerContainer().GetServers()
+	confs := make([]client.ServerConfig, 0, len(servers))
+	states := make([]client.ServerState, 0, len(servers))
+	for _, server := range servers {
+		confs = append(confs, server.Config())
+		states = append(states, server.State())
+	}
+	for _, m := range serverMetrics {
+		w.header(m.name, m.typ, m.help)
+		for i := range servers {
+			if value, ok := m.value(states[i]); ok {
+				w.sample(m.name, serverLabels(confs[i]), value)
+			}
+		}
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	w.header("process_start_time_seconds", "gauge", "Start time of the process since unix epoch in seconds.")
+	w.sample("process_start_time_seconds", "", float64(startTime.Unix()))
+	w.header("go_goroutines", "gauge", "Number of goroutines that currently exist.")
+	w.sample("go_goroutines", "", float64(runtime.NumGoroutine()))
+	w.header("go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.")
+	w.sample("go_memstats_alloc_bytes", "", float64(memStats.Alloc))
+	w.header("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.")
+	w.sample("go_memstats_sys_bytes", "", float64(memStats.Sys))
+	w.header("go_memstats_heap_objects", "gauge", "Number of allocated objects.")
+	w.sample("go_memstats_heap_objects", "", float64(memStats.HeapObjects))
+	w.header("go_gc_cycles_total", "counter", "Number of completed GC cycles.")
+	w.sample("go_gc_cycles_total", "", float64(memStats.NumGC))
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, err := writer.Write(w.buf.Bytes())
+	if err != nil {
+		log.Debugf("write failed, err: %s\n", err)
+		return
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const resolveTimeout = 2 * time.Second
+
+// the ad-hoc query sends udp packets to any address, it is limited to keep the api from being used as a reflector
+var queryLimiters *rateLimiterGroup
+var queryAllowlist []*net.IPNet
+
+// the special ranges which are local or private but not reported so by net.IP
+var queryRestricted = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	// carrier-grade nat
+	mustParseCIDR("100.64.0.0/10"),
+	// benchmark
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+func containsIp(networks []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range networks {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func initQuery() {
+	queryLimiters = newRateLimiterGroup(config.Conf.ApiQueryRateLimit)
+	queryAllowlist = make([]*net.IPNet, 0)
+	for _, entry := range config.Conf.ApiQueryAllowlist {
+		ipNet, err := parseAllowlistEntry(entry)
+		if err != nil {
+			log.Errorf("invalid api query allowlist entry %q, err: %v\n", entry, err)
+			continue
+		}
+		queryAllowlist = append(queryAllowlist, ipNet)
+	}
+}
+
+// parseAllowlistEntry parses an ip or a cidr, an ip is a network of itself.
+func parseAllowlistEntry(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if !strings.Contains(entry, "/") {
+		if ip := net.ParseIP(entry); ip != nil {
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+	}
+	_, ipNet, err := net.ParseCIDR(entry)
+	return ipNet, err
+}
+
+// queryAllowed rejects the broadcast and multicast addresses, the local and private ones are allowed
+// only when the allowlist covers them so the query can not reach into the network of the host.
+func queryAllowed(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.Equal(net.IPv4bcast) {
+		return false
+	}
+	allowlisted := containsIp(queryAllowlist, ip)
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || containsIp(queryRestricted, ip) {
+		return allowlisted
+	}
+	if ip.IsMulticast() {
+		return false
+	}
+	return allowlisted || len(queryAllowlist) == 0
+}
+
+// remoteIp is the ip of the client, the limits are per ip as a client may have no token.
+func remoteIp(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// resolveHost returns the ip of host, an ipv4 one is preferred.
+func resolveHost(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address of host %s", host)
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return addr.IP, nil
+		}
+	}
+	return addrs[0].IP, nil
+}
+
+type queryResponse struct {
+	Address string        `json:"address"`
+	Status  client.Status `json:"status"`
+	// the failed part of a degraded query
+	Error string       `json:"error"`
+	Info  *client.Info `json:"info"`
+}
+
+// query queries any server once, address is host:port, the server does not need to be monitored.
+func query(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	address := strings.TrimSpace(request.URL.Query().Get("address"))
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "address", "address must be host:port")
+		return
+	}
+	if writeRateLimited(writer, queryLimiters.get(remoteIp(request))) {
+		return
+	}
+	ip, err := resolveHost(request.Context(), host)
+	if err != nil {
+		writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "address", fmt.Sprintf("resolve host failed, %v", err))
+		return
+	}
+	if !queryAllowed(ip) {
+		writeError(writer, http.StatusForbidden, codeForbidden, "address is not allowed")
+		return
+	}
+
+	resolved := net.JoinHostPort(ip.String(), port)
+	info, err := client.QueryOnce(request.Context(), resolved)
+	resp := &queryResponse{
+		Address: resolved,
+		Status:  client.StatusOnline,
+		Info:    info,
+	}
+	if err != nil {
+		var validationError *client.ValidationError
+		var degradedError *client.DegradedError
+		switch {
+		case errors.As(err, &validationError):
+			writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "address", validationError.Message)
+			return
+		case errors.As(err, &degradedError):
+			resp.Status = client.StatusDegraded
+			resp.Error = err.Error()
+		default:
+			writeError(writer, http.StatusBadGateway, codeQueryFailed, err.Error())
+			return
+		}
+	}
+	writeJSON(writer, http.StatusOK, resp)
+}
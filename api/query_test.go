@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net"
+	"testing"
+)
+
+func TestQueryAllowed(t *testing.T) {
+	tests := []struct {
+		ip        string
+		allowlist []string
+		want      bool
+	}{
+		{ip: "1.2.3.4", want: true},
+		{ip: "2001:4860::8888", want: true},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::", want: false},
+		{ip: "255.255.255.255", want: false},
+		{ip: "224.1.2.3", want: false},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.1.2", want: false},
+		{ip: "192.168.1.2", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "169.254.1.2", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "224.0.0.251", want: false},
+		{ip: "0.1.2.3", want: false},
+		{ip: "100.64.1.2", want: false},
+		{ip: "100.127.255.255", want: false},
+		{ip: "100.128.0.1", want: true},
+		{ip: "198.18.1.2", want: false},
+		{ip: "198.19.255.255", want: false},
+
+		// the allowlist limits the public addresses and opens the local ones it covers
+		{ip: "1.2.3.4", allowlist: []string{"5.6.7.8"}, want: false},
+		{ip: "5.6.7.8", allowlist: []string{"5.6.7.8"}, want: true},
+		{ip: "127.0.0.1", allowlist: []string{"127.0.0.1"}, want: true},
+		{ip: "::1", allowlist: []string{" ::1 "}, want: true},
+		{ip: "192.168.1.2", allowlist: []string{"192.168.1.0/24"}, want: true},
+		{ip: "192.168.2.2", allowlist: []string{"192.168.1.0/24"}, want: false},
+		{ip: "100.64.1.2", allowlist: []string{"100.64.0.0/10"}, want: true},
+		{ip: "224.1.2.3", allowlist: []string{"224.0.0.0/4"}, want: false},
+		{ip: "0.0.0.0", allowlist: []string{"0.0.0.0/0"}, want: false},
+	}
+	for _, tt := range tests {
+		queryAllowlist = make([]*net.IPNet, 0)
+		for _, entry := range tt.allowlist {
+			ipNet, err := parseAllowlistEntry(entry)
+			if err != nil {
+				t.Fatalf("parseAllowlistEntry(%s) failed, err: %v", entry, err)
+			}
+			queryAllowlist = append(queryAllowlist, ipNet)
+		}
+		if got := queryAllowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("queryAllowed(%s) with allowlist %v = %v, want %v", tt.ip, tt.allowlist, got, tt.want)
+		}
+	}
+	queryAllowlist = nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+)
+
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeRateLimited      = "rate_limited"
+	codeNoData           = "no_data"
+	codeQueryFailed      = "query_failed"
+	codeInternalError    = "internal_error"
+)
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// the invalid parameter, empty when the error is not about one
+	Field string `json:"field,omitempty"`
+}
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error *errorBody `json:"error"`
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		log.Warnf("json.Marshal failed, err: %v\n", err)
+		writeError(writer, http.StatusInternalServerError, codeInternalError, "encode response failed")
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	writer.WriteHeader(statusCode)
+	_, err = writer.Write(bytes)
+	if err != nil {
+		log.Debugf("write failed, err: %s\n", err)
+		return
+	}
+}
+
+func writeError(writer http.ResponseWriter, statusCode int, code string, message string) {
+	writeFieldError(writer, statusCode, code, "", message)
+}
+
+func writeFieldError(writer http.ResponseWriter, statusCode int, code string, field string, message string) {
+	writeJSON(writer, statusCode, &errorResponse{
+		Error: &errorBody{
+			Code:    code,
+			Message: message,
+			Field:   field,
+		},
+	})
+}
+
+func writeMethodNotAllowed(writer http.ResponseWriter, allowed string) {
+	writer.Header().Set("Allow", allowed)
+	writeError(writer, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed")
+}
+
+// notFound answers the unknown api paths, which would be served by the dashboard otherwise.
+func notFound(writer http.ResponseWriter, request *http.Request) {
+	writeError(writer, http.StatusNotFound, codeNotFound, "not found")
+}
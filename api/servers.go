@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type infoSummary struct {
+	ServerName  string  `json:"server_name"`
+	Map         string  `json:"map"`
+	PlayerCount int64   `json:"player_count"`
+	MaxPlayers  int64   `json:"max_players"`
+	Bots        int64   `json:"bots"`
+	LatencyMs   float64 `json:"latency_ms"`
+}
+
+type serverSummary struct {
+	Id          string        `json:"id"`
+	DisplayName string        `json:"display_name"`
+	Address     string        `json:"address"`
+	Ip          string        `json:"ip"`
+	Port        int64         `json:"port"`
+	Remark      string        `json:"remark"`
+	Interval    int64         `json:"interval"`
+	Status      client.Status `json:"status"`
+	// unix time in seconds, 0 when never
+	LastUpdateTime  int64        `json:"last_update_time"`
+	LastSuccessTime int64        `json:"last_success_time"`
+	LastError       string       `json:"last_error"`
+	FailureCount    int64        `json:"failure_count"`
+	Info            *infoSummary `json:"info"`
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func newServerSummary(server *client.Server) *serverSummary {
+	conf := server.Config()
+	state := server.State()
+	summary := &serverSummary{
+		Id:              conf.Id,
+		DisplayName:     conf.DisplayName,
+		Address:         conf.Address(),
+		Ip:              conf.Ip,
+		Port:            conf.Port,
+		Remark:          conf.Remark,
+		Interval:        conf.Interval,
+		Status:          state.Status,
+		LastUpdateTime:  unixTime(state.LastUpdateTime),
+		LastSuccessTime: unixTime(state.LastSuccessTime),
+		LastError:       state.LastError,
+		FailureCount:    state.FailureCount,
+	}
+	if state.Info != nil {
+		summary.Info = &infoSummary{
+			ServerName:  state.Info.ServerName,
+			Map:         state.Info.Map,
+			PlayerCount: state.Info.PlayerCount,
+			MaxPlayers:  state.Info.MaxPlayers,
+			Bots:        state.Info.Bots,
+			LatencyMs:   state.Info.LatencyMs,
+		}
+	}
+	return summary
+}
+
+var validStatuses = map[client.Status]bool{
+	client.StatusUnknown:  true,
+	client.StatusOnline:   true,
+	client.StatusOffline:  true,
+	client.StatusDegraded: true,
+}
+
+func servers(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		listServers(writer, request)
+	case http.MethodPost:
+		createServer(writer, request)
+	case http.MethodPut, http.MethodDelete:
+		server, ok := findServer(writer, request)
+		if !ok {
+			return
+		}
+		if request.Method == http.MethodPut {
+			updateServer(writer, request, server)
+		} else {
+			deleteServer(writer, request, server)
+		}
+	default:
+		writeMethodNotAllowed(writer, "GET, POST, PUT, DELETE")
+	}
+}
+
+func serversV2(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		listServers(writer, request)
+	case http.MethodPost:
+		createServer(writer, request)
+	default:
+		writeMethodNotAllowed(writer, "GET, POST")
+	}
+}
+
+// serverDetail is a server with the full info.
+type serverDetail struct {
+	*serverSummary
+	Info *client.Info `json:"info"`
+}
+
+// serverV2 handles /api/v2/servers/{id} and its sub resources.
+func serverV2(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, "/api/v2/servers/")
+	id, sub, _ := strings.Cut(path, "/")
+	server := client.GetServerContainer().GetServer(id)
+	if id == "" || server == nil {
+		writeError(writer, http.StatusNotFound, codeNotFound, "server not found")
+		return
+	}
+	switch sub {
+	case "":
+	case "history":
+		history(writer, request, server)
+		return
+	default:
+		writeError(writer, http.StatusNotFound, codeNotFound, "not found")
+		return
+	}
+	switch request.Method {
+	case http.MethodGet:
+		writeJSON(writer, http.StatusOK, &serverDetail{
+			serverSummary: newServerSummary(server),
+			Info:          server.State().Info,
+		})
+	case http.MethodPut:
+		updateServer(writer, request, server)
+	case http.MethodDelete:
+		deleteServer(writer, request, server)
+	default:
+		writeMethodNotAllowed(writer, "GET, PUT, DELETE")
+	}
+}
+
+// listServers lists the servers, status takes a comma separated list and name matches
+// a substring of the display name or server name.
+func listServers(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	statuses := make(map[client.Status]bool)
+	if statusRaw := query.Get("status"); statusRaw != "" {
+		for _, s := range strings.Split(statusRaw, ",") {
+			status := client.Status(strings.TrimSpace(s))
+			if !validStatuses[status] {
+				writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "status", fmt.Sprintf("unknown status %q", status))
+				return
+			}
+			statuses[status] = true
+		}
+	}
+	name := strings.ToLower(strings.TrimSpace(query.Get("name")))
+
+	list := make([]*serverSummary, 0)
+	for _, server := range client.GetServerContainer().GetServers() {
+		summary := newServerSummary(server)
+		if len(statuses) > 0 && !statuses[summary.Status] {
+			continue
+		}
+		if name != "" {
+			matched := strings.Contains(strings.ToLower(summary.DisplayName), name)
+			if summary.Info != nil && strings.Contains(strings.ToLower(summary.Info.ServerName), name) {
+				matched = true
+			}
+			if !matched {
+				continue
+			}
+		}
+		list = append(list, summary)
+	}
+
+	writeJSON(writer, http.StatusOK, list)
+}
+
+// serverRequest is the body of create and update, omitted fields keep their current value on update.
+type serverRequest struct {
+	DisplayName string `json:"display_name"`
+	Ip          string `json:"ip"`
+	Port        int64  `json:"port"`
+	Interval    int64  `json:"interval"`
+	Remark      string `json:"remark"`
+	QueryRules  bool   `json:"query_rules"`
+
+	TimeoutMs          *int64 `json:"timeout_ms"`
+	Retries            *int64 `json:"retries"`
+	RetryBackoffMs     *int64 `json:"retry_backoff_ms"`
+	MaxBackoffInterval *int64 `json:"max_backoff_interval"`
+
+	Notify                bool  `json:"notify"`
+	NotifyPlayerThreshold int64 `json:"notify_player_threshold"`
+}
+
+func newServerRequest(conf client.ServerConfig) *serverRequest {
+	return &serverRequest{
+		DisplayName:        conf.DisplayName,
+		Ip:                 conf.Ip,
+		Port:               conf.Port,
+		Interval:           conf.Interval,
+		Remark:             conf.Remark,
+		QueryRules:         conf.QueryRules,
+		TimeoutMs:          conf.TimeoutMs,
+		Retries:            conf.Retries,
+		RetryBackoffMs:     conf.RetryBackoffMs,
+		MaxBackoffInterval: conf.MaxBackoffInterval,
+
+		Notify:                conf.Notify,
+		NotifyPlayerThreshold: conf.NotifyPlayerThreshold,
+	}
+}
+
+func (r *serverRequest) toConfig() client.ServerConfig {
+	return client.ServerConfig{
+		DisplayName:        r.DisplayName,
+		Ip:                 r.Ip,
+		Port:               r.Port,
+		Interval:           r.Interval,
+		Remark:             r.Remark,
+		QueryRules:         r.QueryRules,
+		TimeoutMs:          r.TimeoutMs,
+		Retries:            r.Retries,
+		RetryBackoffMs:     r.RetryBackoffMs,
+		MaxBackoffInterval: r.MaxBackoffInterval,
+
+		Notify:                r.Notify,
+		NotifyPlayerThreshold: r.NotifyPlayerThreshold,
+	}
+}
+
+// decodeServerRequest reads the body over req and validates the result.
+func decodeServerRequest(writer http.ResponseWriter, request *http.Request, req *serverRequest) (client.ServerConfig, bool) {
+	decoder := json.NewDecoder(request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		writeError(writer, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("invalid body, %v", err))
+		return client.ServerConfig{}, false
+	}
+	conf := req.toConfig()
+	if err := conf.Validate(); err != nil {
+		var validationError *client.ValidationError
+		if errors.As(err, &validationError) {
+			writeFieldError(writer, http.StatusBadRequest, codeBadRequest, validationError.Field, validationError.Message)
+		} else {
+			writeError(writer, http.StatusBadRequest, codeBadRequest, err.Error())
+		}
+		return client.ServerConfig{}, false
+	}
+	return conf, true
+}
+
+// findServer finds the server by the host and port parameters, the error is written when it fails.
+func findServer(writer http.ResponseWriter, request *http.Request) (*client.Server, bool) {
+	query := request.URL.Query()
+	host := query.Get("host")
+	if host == "" {
+		writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "host", "host is required")
+		return nil, false
+	}
+	port, err := strconv.ParseInt(query.Get("port"), 10, 64)
+	if err != nil || port < 1 || port > 65535 {
+		writeFieldError(writer, http.StatusBadRequest, codeBadRequest, "port", "port must be a number between 1 and 65535")
+		return nil, false
+	}
+	for _, s := range client.GetServerContainer().GetServers() {
+		conf := s.Config()
+		if conf.Ip == host && conf.Port == port {
+			return s, true
+		}
+	}
+	writeError(writer, http.StatusNotFound, codeNotFound, "server not found")
+	return nil, false
+}
+
+func saveServers(writer http.ResponseWriter) bool {
+	if err := client.SaveServers(); err != nil {
+		log.Warnf("SaveServers failed, err: %v\n", err)
+		writeError(writer, http.StatusInternalServerError, codeInternalError, "save config failed")
+		return false
+	}
+	return true
+}
+
+func createServer(writer http.ResponseWriter, request *http.Request) {
+	req := &serverRequest{
+		Interval: 10,
+	}
+	conf, ok := decodeServerRequest(writer, request, req)
+	if !ok {
+		return
+	}
+	server := client.NewServer(conf)
+	client.GetServerContainer().AddServer(server)
+	server.Start()
+	if !saveServers(writer) {
+		return
+	}
+	writeJSON(writer, http.StatusCreated, newServerSummary(server))
+}
+
+func updateServer(writer http.ResponseWriter, request *http.Request, server *client.Server) {
+	conf, ok := decodeServerRequest(writer, request, newServerRequest(server.Config()))
+	if !ok {
+		return
+	}
+	client.GetServerContainer().UpdateServer(server, conf)
+	if !saveServers(writer) {
+		return
+	}
+	writeJSON(writer, http.StatusOK, newServerSummary(server))
+}
+
+func deleteServer(writer http.ResponseWriter, request *http.Request, server *client.Server) {
+	client.GetServerContainer().RemoveServer(server)
+	if !saveServers(writer) {
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
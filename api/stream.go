@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/client"
+	"github.com/comoyi/steam-server-monitor/log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const streamPath = "/api/v1/stream"
+
+const streamKeepAliveInterval = 15 * time.Second
+
+// a slow client misses events rather than blocking the refresh
+const streamBufferSize = 64
+
+type streamEvent struct {
+	name     string
+	serverId string
+	data     []byte
+}
+
+// streamHub fans out server events to the connected stream clients.
+type streamHub struct {
+	subscribers map[chan *streamEvent]bool
+	statuses    map[*client.Server]client.Status
+	mu          sync.Mutex
+}
+
+var hub = &streamHub{
+	subscribers: make(map[chan *streamEvent]bool),
+	statuses:    make(map[*client.Server]client.Status),
+}
+
+var initStreamOnce sync.Once
+
+func initStream() {
+	initStreamOnce.Do(func() {
+		container := client.GetServerContainer()
+		container.AddListener(hub.onRefresh)
+		container.AddChangeListener(hub.onChange)
+	})
+}
+
+func (h *streamHub) subscribe() chan *streamEvent {
+	ch := make(chan *streamEvent, streamBufferSize)
+	h.mu.Lock()
+	h.subscribers[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *streamHub) unsubscribe(ch chan *streamEvent) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+func (h *streamHub) publish(event *streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Debugf("stream client is too slow, event dropped\n")
+		}
+	}
+}
+
+// onRefresh publishes new info and status changes, a server which stays offline has nothing new.
+func (h *streamHub) onRefresh(server *client.Server) {
+	status := server.State().Status
+	h.mu.Lock()
+	previous := h.statuses[server]
+	h.statuses[server] = status
+	h.mu.Unlock()
+	if status == client.StatusOffline && previous == client.StatusOffline {
+		return
+	}
+	h.publishServer("update", server)
+}
+
+func (h *streamHub) onChange(server *client.Server, change client.Change) {
+	if change == client.ChangeRemoved {
+		h.mu.Lock()
+		delete(h.statuses, server)
+		h.mu.Unlock()
+	}
+	h.publishServer(string(change), server)
+}
+
+func (h *streamHub) publishServer(name string, server *client.Server) {
+	event, err := newServerEvent(name, server)
+	if err != nil {
+		log.Warnf("newServerEvent failed, err: %v\n", err)
+		return
+	}
+	h.publish(event)
+}
+
+func newServerEvent(name string, server *client.Server) (*streamEvent, error) {
+	data, err := json.Marshal(&serverDetail{
+		serverSummary: newServerSummary(server),
+		Info:          server.State().Info,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &streamEvent{
+		name:     name,
+		serverId: server.Config().Id,
+		data:     data,
+	}, nil
+}
+
+// stream sends server events with Server-Sent Events, id takes a comma separated list of server ids.
+// A snapshot of the servers is sent first.
+func stream(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		writeMethodNotAllowed(writer, http.MethodGet)
+		return
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeError(writer, http.StatusInternalServerError, codeInternalError, "streaming is not supported")
+		return
+	}
+	ids := make(map[string]bool)
+	if idRaw := request.URL.Query().Get("id"); idRaw != "" {
+		for _, id := range strings.Split(idRaw, ",") {
+			ids[strings.TrimSpace(id)] = true
+		}
+	}
+	match := func(event *streamEvent) bool {
+		return len(ids) == 0 || ids[event.serverId]
+	}
+
+	ch := hub.subscribe()
+	defer hub.unsubscribe(ch)
+
+	header := writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+
+	var seq int64 = 0
+	write := func(event *streamEvent) error {
+		seq++
+		_, err := fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", seq, event.name, event.data)
+		return err
+	}
+
+	for _, server := range client.GetServerContainer().GetServers() {
+		event, err := newServerEvent("snapshot", server)
+		if err != nil {
+			log.Warnf("newServerEvent failed, err: %v\n", err)
+			continue
+		}
+		if !match(event) {
+			continue
+		}
+		if err := write(event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event := <-ch:
+			if !match(event) {
+				continue
+			}
+			if err := write(event); err != nil {
+				log.Debugf("write stream failed, err: %s\n", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
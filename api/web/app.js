@@ -0,0 +1,253 @@
+'use strict';
+
+const statusTexts = {
+    unknown: '未知',
+    online: '在线',
+    offline: '离线',
+    degraded: '异常',
+};
+
+// server id -> {data, el, range, chartOpen, chartTime}
+const views = new Map();
+
+// the api token, taken from ?token= once and kept in the browser
+let token = new URLSearchParams(location.search).get('token') || localStorage.getItem('token') || '';
+if (token) {
+    localStorage.setItem('token', token);
+}
+
+function apiFetch(path) {
+    const headers = token ? {Authorization: 'Bearer ' + token} : {};
+    return fetch(path, {headers: headers});
+}
+
+function formatDuration(second) {
+    const d = Math.floor(second / 86400);
+    const h = Math.floor(second % 86400 / 3600);
+    const m = Math.floor(second % 3600 / 60);
+    const s = Math.floor(second % 60);
+    let str = '';
+    if (d > 0) {
+        str += d + '天';
+    }
+    if (str || h > 0) {
+        str += h + '时';
+    }
+    if (str || m > 0) {
+        str += m + '分';
+    }
+    return str + s + '秒';
+}
+
+function formatPlayerCount(info) {
+    if (info.max_players <= 0) {
+        return String(info.player_count);
+    }
+    let s = info.player_count + '/' + info.max_players;
+    if (info.player_count >= info.max_players) {
+        s += '（已满）';
+    }
+    return s;
+}
+
+function createView(id) {
+    const el = document.getElementById('server-template').content.firstElementChild.cloneNode(true);
+    const view = {data: null, el: el, range: '24h', chartOpen: false, chartTime: 0};
+
+    el.querySelector('.toggle-players').addEventListener('click', () => {
+        const list = el.querySelector('.players');
+        list.hidden = !list.hidden;
+    });
+    el.querySelector('.toggle-chart').addEventListener('click', () => {
+        view.chartOpen = !view.chartOpen;
+        el.querySelector('.chart').hidden = !view.chartOpen;
+        if (view.chartOpen) {
+            loadChart(id, view);
+        }
+    });
+    for (const button of el.querySelectorAll('.ranges button')) {
+        button.addEventListener('click', () => {
+            view.range = button.dataset.range;
+            for (const b of el.querySelectorAll('.ranges button')) {
+                b.classList.toggle('active', b === button);
+            }
+            loadChart(id, view);
+        });
+    }
+
+    document.getElementById('servers').appendChild(el);
+    views.set(id, view);
+    return view;
+}
+
+function render(server) {
+    const view = views.get(server.id) || createView(server.id);
+    view.data = server;
+    const el = view.el;
+    const info = server.info;
+    const online = info && server.status !== 'offline';
+
+    const badge = el.querySelector('.badge');
+    badge.className = 'badge ' + server.status;
+    badge.textContent = '[' + (statusTexts[server.status] || statusTexts.unknown) + ']';
+
+    let name = server.display_name;
+    if (!name && info) {
+        name = info.server_name;
+    }
+    el.querySelector('.name').textContent = '服务器：' + (name || '-');
+    el.querySelector('.address').textContent = '地址：' + server.address;
+    el.querySelector('.remark').textContent = '备注：' + server.remark;
+    el.querySelector('.player-count').textContent = '在线人数：' + (online ? formatPlayerCount(info) : '-');
+    el.querySelector('.map').textContent = '地图：' + (online && info.map ? info.map : '-');
+    el.querySelector('.latency').textContent = '延迟：' + (online ? Math.round(info.latency_ms) + 'ms' : '-');
+
+    const players = (online && info.players) ? info.players.filter(p => p) : [];
+    let maxDuration = 0;
+    for (const p of players) {
+        maxDuration = Math.max(maxDuration, p.duration);
+    }
+    el.querySelector('.max-duration').textContent = '最长在线：' + (players.length > 0 ? formatDuration(maxDuration) : '-');
+
+    const list = el.querySelector('.players');
+    list.replaceChildren();
+    players.forEach((p, i) => {
+        const item = document.createElement('li');
+        item.textContent = '玩家' + String(i + 1).padStart(2, ' ') + ' 连续在线 ' + formatDuration(p.duration) + ' ' + p.name;
+        list.appendChild(item);
+    });
+    if (players.length === 0) {
+        const item = document.createElement('li');
+        item.textContent = '暂无玩家';
+        list.appendChild(item);
+    }
+
+    // the history changes slowly, refresh the open chart at most once a minute
+    if (view.chartOpen && Date.now() - view.chartTime > 60000) {
+        loadChart(server.id, view);
+    }
+    updateEmpty();
+}
+
+function remove(id) {
+    const view = views.get(id);
+    if (view) {
+        view.el.remove();
+        views.delete(id);
+    }
+    updateEmpty();
+}
+
+function updateEmpty() {
+    document.getElementById('empty').hidden = views.size > 0;
+}
+
+async function loadChart(id, view) {
+    view.chartTime = Date.now();
+    const response = await apiFetch('api/v2/servers/' + encodeURIComponent(id) + '/history?range=' + view.range);
+    if (!response.ok) {
+        return;
+    }
+    drawChart(view.el.querySelector('canvas'), await response.json());
+}
+
+// drawChart draws the player counts, offline periods are left as gaps.
+function drawChart(canvas, history) {
+    const ratio = window.devicePixelRatio || 1;
+    const width = canvas.clientWidth;
+    const height = canvas.clientHeight;
+    canvas.width = width * ratio;
+    canvas.height = height * ratio;
+    const ctx = canvas.getContext('2d');
+    ctx.scale(ratio, ratio);
+    ctx.clearRect(0, 0, width, height);
+
+    const padding = {left: 28, right: 8, top: 8, bottom: 18};
+    const samples = history.samples || [];
+    let max = 1;
+    for (const s of samples) {
+        max = Math.max(max, s.player_count, s.max_players);
+    }
+    const x = t => padding.left + (t - history.from) / (history.to - history.from) * (width - padding.left - padding.right);
+    const y = v => height - padding.bottom - v / max * (height - padding.top - padding.bottom);
+
+    ctx.strokeStyle = '#ddd';
+    ctx.fillStyle = '#888';
+    ctx.font = '10px sans-serif';
+    ctx.beginPath();
+    ctx.moveTo(padding.left, y(0));
+    ctx.lineTo(width - padding.right, y(0));
+    ctx.moveTo(padding.left, y(max));
+    ctx.lineTo(width - padding.right, y(max));
+    ctx.stroke();
+    ctx.fillText('0', 4, y(0) + 3);
+    ctx.fillText(String(max), 4, y(max) + 3);
+    ctx.fillText(new Date(history.from * 1000).toLocaleString(), padding.left, height - 4);
+    const now = new Date(history.to * 1000).toLocaleString();
+    ctx.fillText(now, width - padding.right - ctx.measureText(now).width, height - 4);
+
+    if (samples.length === 0) {
+        ctx.fillText('暂无数据', width / 2 - 20, height / 2);
+        return;
+    }
+
+    ctx.strokeStyle = '#1e88e5';
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    let drawing = false;
+    for (const s of samples) {
+        if (s.status === 'offline') {
+            drawing = false;
+            ctx.fillStyle = '#e53935';
+            ctx.fillRect(x(s.time) - 1, y(0) - 2, 2, 2);
+            continue;
+        }
+        if (drawing) {
+            ctx.lineTo(x(s.time), y(s.player_count));
+        } else {
+            ctx.moveTo(x(s.time), y(s.player_count));
+            drawing = true;
+        }
+    }
+    ctx.stroke();
+}
+
+function setConnection(online) {
+    const el = document.getElementById('connection');
+    el.className = 'connection ' + (online ? 'online' : 'offline');
+    el.textContent = online ? '实时更新中' : '连接断开，重连中';
+}
+
+async function load() {
+    const response = await apiFetch('api/v2/servers');
+    if (response.status === 401) {
+        const input = prompt('请输入访问令牌');
+        if (input === null) {
+            return;
+        }
+        token = input;
+        localStorage.setItem('token', token);
+        return load();
+    }
+    if (!response.ok) {
+        return;
+    }
+    for (const server of await response.json()) {
+        render(server);
+    }
+}
+
+function connect() {
+    // EventSource can not set headers
+    const source = new EventSource(token ? 'api/v1/stream?token=' + encodeURIComponent(token) : 'api/v1/stream');
+    const onServer = event => render(JSON.parse(event.data));
+    source.addEventListener('open', () => setConnection(true));
+    source.addEventListener('error', () => setConnection(false));
+    source.addEventListener('snapshot', onServer);
+    source.addEventListener('update', onServer);
+    source.addEventListener('added', onServer);
+    source.addEventListener('updated', onServer);
+    source.addEventListener('removed', event => remove(JSON.parse(event.data).id));
+}
+
+load().finally(connect);
@@ -1,22 +1,50 @@
 package app
 
 import (
+	"context"
+	"flag"
+	"github.com/comoyi/steam-server-monitor/alert"
 	"github.com/comoyi/steam-server-monitor/api"
 	"github.com/comoyi/steam-server-monitor/client"
 	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"time"
 )
 
+var headless = flag.Bool("headless", false, "run monitoring and the api without GUI")
+
+var apiServer = api.NewServer()
+
 func Start() {
+	flag.Parse()
 	initApp()
-	if config.Conf.EnableApi {
-		go func() {
-			api.Start()
-		}()
+	alert.Init()
+	applyApi()
+	config.AddApiListener(applyApi)
+	if *headless {
+		client.StartHeadless()
+		shutdownApi()
+		return
 	}
 	client.Start()
+	shutdownApi()
 }
 
 func initApp() {
 	config.LoadConfig()
 	_ = config.SaveConfig()
 }
+
+// applyApi starts, restarts or stops the api to match the config, the api is the only output in headless mode.
+func applyApi() {
+	err := apiServer.Apply(context.Background(), config.GetApiSettings().Enable || *headless)
+	if err != nil {
+		log.Errorf("apply api failed, err: %v\n", err)
+	}
+}
+
+func shutdownApi() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = apiServer.Shutdown(ctx)
+}
@@ -0,0 +1,256 @@
+//go:build !headless
+
+package client
+
+import (
+	"fmt"
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	theme2 "fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/comoyi/steam-server-monitor/store"
+	"image/color"
+	"sync"
+	"time"
+)
+
+const chartBuckets = 120
+
+// max number of empty buckets between two points to still connect them
+const chartMaxGap = 5
+
+var chartOfflineColor = color.NRGBA{R: 0xf4, G: 0x43, B: 0x36, A: 0x40}
+
+type chartBucket struct {
+	hasValue bool
+	value    int64
+	offline  bool
+}
+
+// historyChart draws the player count history with canvas primitives, offline periods are shaded.
+type historyChart struct {
+	widget.BaseWidget
+
+	samples []*store.Sample
+	from    time.Time
+	to      time.Time
+	mu      sync.Mutex
+}
+
+func newHistoryChart() *historyChart {
+	c := &historyChart{}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+func (c *historyChart) SetData(samples []*store.Sample, from time.Time, to time.Time) {
+	c.mu.Lock()
+	c.samples = samples
+	c.from = from
+	c.to = to
+	c.mu.Unlock()
+	c.Refresh()
+}
+
+func (c *historyChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &historyChartRenderer{
+		chart:      c,
+		background: canvas.NewRectangle(theme2.InputBackgroundColor()),
+	}
+	r.rebuild()
+	return r
+}
+
+// buckets downsamples the samples to a fixed number of buckets, each keeps the max player count.
+func (c *historyChart) buckets() ([]chartBucket, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	buckets := make([]chartBucket, chartBuckets)
+	var maxValue int64 = 0
+	span := c.to.Sub(c.from)
+	if span <= 0 {
+		return buckets, maxValue
+	}
+	for _, sample := range c.samples {
+		i := int(time.Unix(sample.Time, 0).Sub(c.from) * chartBuckets / span)
+		if i < 0 || i >= chartBuckets {
+			continue
+		}
+		if sample.Status == store.StatusOffline {
+			buckets[i].offline = true
+			continue
+		}
+		if !buckets[i].hasValue || sample.PlayerCount > buckets[i].value {
+			buckets[i].value = sample.PlayerCount
+		}
+		buckets[i].hasValue = true
+		if sample.PlayerCount > maxValue {
+			maxValue = sample.PlayerCount
+		}
+		if sample.MaxPlayers > maxValue {
+			maxValue = sample.MaxPlayers
+		}
+	}
+	return buckets, maxValue
+}
+
+type chartSegment struct {
+	line   *canvas.Line
+	from   int
+	to     int
+	value1 int64
+	value2 int64
+}
+
+type chartShade struct {
+	rect *canvas.Rectangle
+	from int
+	to   int
+}
+
+type historyChartRenderer struct {
+	chart      *historyChart
+	background *canvas.Rectangle
+	segments   []*chartSegment
+	shades     []*chartShade
+	maxText    *canvas.Text
+	zeroText   *canvas.Text
+	fromText   *canvas.Text
+	toText     *canvas.Text
+	emptyText  *canvas.Text
+	maxValue   int64
+	objects    []fyne.CanvasObject
+}
+
+func (r *historyChartRenderer) rebuild() {
+	buckets, maxValue := r.chart.buckets()
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	r.maxValue = maxValue
+	r.segments = make([]*chartSegment, 0)
+	r.shades = make([]*chartShade, 0)
+
+	var shade *chartShade
+	prev := -1
+	hasData := false
+	for i, b := range buckets {
+		if b.offline && !b.hasValue {
+			if shade == nil || shade.to != i {
+				shade = &chartShade{rect: canvas.NewRectangle(chartOfflineColor), from: i}
+				r.shades = append(r.shades, shade)
+			}
+			shade.to = i + 1
+			prev = -1
+			continue
+		}
+		if !b.hasValue {
+			continue
+		}
+		hasData = true
+		// connect the points unless the monitor has not polled for a while
+		if prev >= 0 && i-prev <= chartMaxGap {
+			line := canvas.NewLine(theme2.PrimaryColor())
+			line.StrokeWidth = 2
+			r.segments = append(r.segments, &chartSegment{line: line, from: prev, to: i, value1: buckets[prev].value, value2: b.value})
+		} else {
+			// a single point after a gap is drawn as a short flat line
+			line := canvas.NewLine(theme2.PrimaryColor())
+			line.StrokeWidth = 2
+			r.segments = append(r.segments, &chartSegment{line: line, from: i, to: i, value1: b.value, value2: b.value})
+		}
+		prev = i
+	}
+
+	r.chart.mu.Lock()
+	from, to := r.chart.from, r.chart.to
+	r.chart.mu.Unlock()
+	layout := "15:04"
+	if to.Sub(from) > 24*time.Hour {
+		layout = "01-02"
+	}
+
+	textColor := theme2.ForegroundColor()
+	textSize := theme2.CaptionTextSize()
+	newText := func(s string) *canvas.Text {
+		t := canvas.NewText(s, textColor)
+		t.TextSize = textSize
+		return t
+	}
+	r.maxText = newText(fmt.Sprintf("%d", maxValue))
+	r.zeroText = newText("0")
+	r.fromText = newText(from.Format(layout))
+	r.toText = newText(to.Format(layout))
+	r.emptyText = newText("")
+	if !hasData && len(r.shades) == 0 {
+		r.emptyText.Text = "暂无数据"
+	}
+
+	objects := []fyne.CanvasObject{r.background}
+	for _, s := range r.shades {
+		objects = append(objects, s.rect)
+	}
+	for _, s := range r.segments {
+		objects = append(objects, s.line)
+	}
+	objects = append(objects, r.maxText, r.zeroText, r.fromText, r.toText, r.emptyText)
+	r.objects = objects
+}
+
+func (r *historyChartRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+	r.background.Move(fyne.NewPos(0, 0))
+
+	left := r.maxText.MinSize().Width + 6
+	bottom := r.fromText.MinSize().Height + 2
+	top := r.maxText.MinSize().Height / 2
+	plotWidth := size.Width - left - 4
+	plotHeight := size.Height - bottom - top
+	if plotWidth <= 0 || plotHeight <= 0 {
+		return
+	}
+	bucketWidth := plotWidth / chartBuckets
+	x := func(i int) float32 {
+		return left + bucketWidth*float32(i) + bucketWidth/2
+	}
+	y := func(v int64) float32 {
+		return top + plotHeight - plotHeight*float32(v)/float32(r.maxValue)
+	}
+
+	for _, s := range r.shades {
+		s.rect.Move(fyne.NewPos(left+bucketWidth*float32(s.from), top))
+		s.rect.Resize(fyne.NewSize(bucketWidth*float32(s.to-s.from), plotHeight))
+	}
+	for _, s := range r.segments {
+		x1, x2 := x(s.from), x(s.to)
+		if s.from == s.to {
+			x1 -= bucketWidth / 2
+			x2 += bucketWidth / 2
+		}
+		s.line.Position1 = fyne.NewPos(x1, y(s.value1))
+		s.line.Position2 = fyne.NewPos(x2, y(s.value2))
+	}
+
+	r.maxText.Move(fyne.NewPos(2, 0))
+	r.zeroText.Move(fyne.NewPos(left-r.zeroText.MinSize().Width-4, top+plotHeight-r.zeroText.MinSize().Height/2))
+	r.fromText.Move(fyne.NewPos(left, size.Height-r.fromText.MinSize().Height))
+	r.toText.Move(fyne.NewPos(size.Width-r.toText.MinSize().Width-4, size.Height-r.toText.MinSize().Height))
+	r.emptyText.Move(fyne.NewPos((size.Width-r.emptyText.MinSize().Width)/2, (size.Height-r.emptyText.MinSize().Height)/2))
+}
+
+func (r *historyChartRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(200, 120)
+}
+
+func (r *historyChartRenderer) Refresh() {
+	r.rebuild()
+	r.Layout(r.chart.Size())
+	canvas.Refresh(r.chart)
+}
+
+func (r *historyChartRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *historyChartRenderer) Destroy() {
+}
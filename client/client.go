@@ -3,35 +3,142 @@ package client
 import (
 	"github.com/comoyi/steam-server-monitor/config"
 	"github.com/comoyi/steam-server-monitor/log"
+	"github.com/spf13/viper"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
 )
 
 var appName = "Steam服务器信息查看器"
 var versionText = "1.0.9"
 
-func Start() {
-	log.Debugf("Client start\n")
+// StartHeadless runs the monitoring loop without GUI and blocks until the process is interrupted.
+func StartHeadless() {
+	log.Debugf("Client start headless\n")
 
-	initUI()
+	initHistory()
+	defer closeHistory()
+	initSessions()
 
 	loadServers()
 
-	go func() {
-		run()
-	}()
+	startServers(serverContainer.GetServers())
 
-	w.ShowAndRun()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+	log.Infof("Client stop, signal: %v\n", sig)
 }
 
+// loadServers creates the servers of the config, missing or duplicate ids are generated and written back.
 func loadServers() {
+	ids := make(map[string]bool)
+	generated := false
 	for _, s := range config.Conf.Servers {
-		server := NewServer(s.DisplayName, s.Ip, s.Port, s.Interval, s.Remark)
+		id := s.Id
+		if id == "" || ids[id] {
+			id = newServerId()
+			generated = true
+			address := net.JoinHostPort(s.Ip, strconv.FormatInt(s.Port, 10))
+			log.Infof("generate id %s for server %s\n", id, address)
+			if historyStore != nil && s.Id == "" {
+				// the history was keyed by address before servers had ids
+				err := historyStore.RenameServer(address, id)
+				if err != nil {
+					log.Warnf("RenameServer failed, err: %v\n", err)
+				}
+			}
+		}
+		ids[id] = true
+		server := NewServer(ServerConfig{
+			Id:          id,
+			DisplayName: s.DisplayName,
+			Ip:          s.Ip,
+			Port:        s.Port,
+			Interval:    s.Interval,
+			Remark:      s.Remark,
+			QueryRules:  s.QueryRules,
+
+			TimeoutMs:          s.TimeoutMs,
+			Retries:            s.Retries,
+			RetryBackoffMs:     s.RetryBackoffMs,
+			MaxBackoffInterval: s.MaxBackoffInterval,
+
+			Notify:                s.Notify,
+			NotifyPlayerThreshold: s.NotifyPlayerThreshold,
+		})
 		serverContainer.AddServer(server)
 	}
+	if generated {
+		if err := SaveServers(); err != nil {
+			log.Warnf("SaveServers failed, err: %v\n", err)
+		}
+	}
 }
 
-func run() {
+// startServers spreads the first refreshes so a large server list does not query all servers at once.
+func startServers(servers []*Server) {
+	maxJitter := time.Duration(config.Conf.QueryStartJitter) * time.Second
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for _, server := range servers {
+		jitter := maxJitter
+		interval := time.Duration(server.Config().Interval) * time.Second
+		if interval < jitter {
+			jitter = interval
+		}
+		var delay time.Duration = 0
+		if jitter > 0 {
+			delay = time.Duration(r.Int63n(int64(jitter)))
+		}
+		server.StartAfter(delay)
+	}
+}
+
+func resetServerConfig() {
+	serverConfig := make([]map[string]interface{}, 0)
 	for _, server := range serverContainer.GetServers() {
-		bind(server)
-		server.Start()
+		conf := server.Config()
+		serverMap := map[string]interface{}{
+			"id":           conf.Id,
+			"display_name": conf.DisplayName,
+			"ip":           conf.Ip,
+			"port":         conf.Port,
+			"interval":     conf.Interval,
+			"remark":       conf.Remark,
+			"query_rules":  conf.QueryRules,
+			"notify":       conf.Notify,
+		}
+		if conf.NotifyPlayerThreshold > 0 {
+			serverMap["notify_player_threshold"] = conf.NotifyPlayerThreshold
+		}
+		if conf.TimeoutMs != nil {
+			serverMap["timeout_ms"] = *conf.TimeoutMs
+		}
+		if conf.Retries != nil {
+			serverMap["retries"] = *conf.Retries
+		}
+		if conf.RetryBackoffMs != nil {
+			serverMap["retry_backoff_ms"] = *conf.RetryBackoffMs
+		}
+		if conf.MaxBackoffInterval != nil {
+			serverMap["max_backoff_interval"] = *conf.MaxBackoffInterval
+		}
+		serverConfig = append(serverConfig, serverMap)
 	}
+	viper.Set("servers", serverConfig)
+}
+
+var saveServersMu sync.Mutex
+
+// SaveServers writes the servers of the container to the config file.
+func SaveServers() error {
+	saveServersMu.Lock()
+	defer saveServersMu.Unlock()
+	resetServerConfig()
+	return config.SaveConfig()
 }
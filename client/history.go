@@ -0,0 +1,88 @@
+package client
+
+import (
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"github.com/comoyi/steam-server-monitor/store"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var historyStore *store.Store
+var historyStop chan struct{}
+
+// initHistory opens the history store under the config dir and records every refresh into it.
+func initHistory() {
+	if !config.Conf.HistoryEnabled {
+		return
+	}
+	configDirPath, err := config.GetConfigDirPath()
+	if err != nil {
+		log.Warnf("Get configDirPath failed, err: %v\n", err)
+		return
+	}
+	err = os.MkdirAll(configDirPath, os.ModePerm)
+	if err != nil {
+		log.Warnf("os.MkdirAll failed, err: %v\n", err)
+		return
+	}
+
+	day := 24 * time.Hour
+	s, err := store.Open(filepath.Join(configDirPath, "history.db"), store.Options{
+		RawRetention:    time.Duration(config.Conf.HistoryRawRetention) * day,
+		RollupRetention: time.Duration(config.Conf.HistoryRollupRetention) * day,
+		RollupInterval:  time.Duration(config.Conf.HistoryRollupInterval) * time.Second,
+
+		SessionRetention: time.Duration(config.Conf.HistorySessionRetention) * day,
+	})
+	if err != nil {
+		log.Errorf("Open history store failed, err: %v\n", err)
+		return
+	}
+	historyStore = s
+	historyStop = make(chan struct{})
+	historyStore.StartMaintenance(10*time.Minute, historyStop)
+	serverContainer.AddListener(recordHistory)
+}
+
+func closeHistory() {
+	if historyStore == nil {
+		return
+	}
+	close(historyStop)
+	err := historyStore.Close()
+	if err != nil {
+		log.Warnf("Close history store failed, err: %v\n", err)
+	}
+}
+
+func historyKey(server *Server) string {
+	return server.Config().Id
+}
+
+// GetHistoryPoints returns at most points samples of the server in [from, to) spread over the range,
+// empty when the history is disabled.
+func GetHistoryPoints(server *Server, from time.Time, to time.Time, points int) ([]*store.Sample, error) {
+	if historyStore == nil {
+		return make([]*store.Sample, 0), nil
+	}
+	return historyStore.HistoryPoints(historyKey(server), from, to, points)
+}
+
+func recordHistory(server *Server) {
+	state := server.State()
+	sample := &store.Sample{
+		Time:   time.Now().Unix(),
+		Status: string(state.Status),
+	}
+	if state.Status != StatusOffline && state.Info != nil {
+		sample.PlayerCount = state.Info.PlayerCount
+		sample.MaxPlayers = state.Info.MaxPlayers
+		sample.LatencyMs = state.Info.LatencyMs
+	}
+	err := historyStore.AddSample(historyKey(server), sample)
+	if err != nil {
+		log.Warnf("AddSample failed, err: %v\n", err)
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"github.com/microcosm-cc/bluemonday"
+	"sync"
+	"time"
+)
+
+const (
+	notifyOffline   = "offline"
+	notifyOnline    = "online"
+	notifyThreshold = "threshold"
+	notifyFull      = "full"
+	notifyEmpty     = "empty"
+)
+
+type notifyState struct {
+	initialized bool
+	offline     bool
+	above       bool
+	full        bool
+	empty       bool
+	lastSent    map[string]time.Time
+}
+
+// Notifier sends a notification when a server goes offline, comes back, crosses the player threshold,
+// becomes full or empty. A state is only left again after the player count moved back by the hysteresis,
+// and the same kind of notification is sent at most once per cooldown.
+type Notifier struct {
+	send   func(title string, content string)
+	states map[*Server]*notifyState
+	mu     sync.Mutex
+}
+
+func NewNotifier(send func(title string, content string)) *Notifier {
+	return &Notifier{
+		send:   send,
+		states: make(map[*Server]*notifyState),
+	}
+}
+
+func initNotifier(send func(title string, content string)) {
+	notifier := NewNotifier(send)
+	serverContainer.AddListener(notifier.check)
+	serverContainer.AddChangeListener(notifier.forget)
+}
+
+// forget drops the state of a removed server, and of an edited one as its address may have changed.
+func (n *Notifier) forget(server *Server, change Change) {
+	if change == ChangeAdded {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.states, server)
+}
+
+func (n *Notifier) check(server *Server) {
+	conf := server.Config()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !conf.Notify {
+		delete(n.states, server)
+		return
+	}
+	ns, ok := n.states[server]
+	if !ok {
+		ns = &notifyState{
+			lastSent: make(map[string]time.Time),
+		}
+		n.states[server] = ns
+	}
+
+	state := server.State()
+	title := conf.DisplayName
+	if title == "" {
+		title = conf.Address()
+		if state.Info != nil && state.Info.ServerName != "" {
+			title = bluemonday.StrictPolicy().Sanitize(state.Info.ServerName)
+		}
+	}
+
+	if state.Status == StatusOffline {
+		// a server never seen online may be a wrong address rather than a server going down
+		if ns.initialized && !ns.offline && state.FailureCount >= config.Conf.NotifyOfflineFailures {
+			ns.offline = true
+			n.notify(ns, notifyOffline, title, fmt.Sprintf("服务器离线：%s", state.LastError))
+		}
+		return
+	}
+	if state.Info == nil {
+		return
+	}
+	if ns.offline {
+		ns.offline = false
+		n.notify(ns, notifyOnline, title, "服务器恢复在线")
+	}
+
+	count := state.Info.PlayerCount
+	maxPlayers := state.Info.MaxPlayers
+	hysteresis := config.Conf.NotifyHysteresis
+	if hysteresis < 1 {
+		hysteresis = 1
+	}
+	threshold := conf.NotifyPlayerThreshold
+
+	// the first result only sets the states, the server was not observed crossing them
+	if !ns.initialized {
+		ns.initialized = true
+		ns.above = threshold > 0 && count >= threshold
+		ns.full = maxPlayers > 0 && count >= maxPlayers
+		ns.empty = count == 0
+		return
+	}
+
+	if threshold > 0 {
+		if !ns.above && count >= threshold {
+			ns.above = true
+			n.notify(ns, notifyThreshold, title, fmt.Sprintf("在线人数达到 %d（%d/%d）", threshold, count, maxPlayers))
+		} else if ns.above && count <= threshold-hysteresis {
+			ns.above = false
+		}
+	} else {
+		ns.above = false
+	}
+
+	if maxPlayers > 0 {
+		if !ns.full && count >= maxPlayers {
+			ns.full = true
+			n.notify(ns, notifyFull, title, fmt.Sprintf("服务器已满（%d/%d）", count, maxPlayers))
+		} else if ns.full && count <= maxPlayers-hysteresis {
+			ns.full = false
+		}
+	}
+
+	if !ns.empty && count == 0 {
+		ns.empty = true
+		n.notify(ns, notifyEmpty, title, "服务器已无玩家")
+	} else if ns.empty && count >= hysteresis {
+		ns.empty = false
+	}
+}
+
+func (n *Notifier) notify(ns *notifyState, kind string, title string, content string) {
+	cooldown := time.Duration(config.Conf.NotifyCooldown) * time.Second
+	now := time.Now()
+	if last, ok := ns.lastSent[kind]; ok && now.Sub(last) < cooldown {
+		log.Debugf("notification skipped in cooldown, kind: %s, title: %s\n", kind, title)
+		return
+	}
+	ns.lastSent[kind] = now
+	log.Infof("notify, kind: %s, title: %s, content: %s\n", kind, title, content)
+	n.send(title, content)
+}
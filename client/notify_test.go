@@ -0,0 +1,106 @@
+package client
+
+import (
+	"github.com/comoyi/steam-server-monitor/config"
+	"reflect"
+	"testing"
+)
+
+type sentNotification struct {
+	title   string
+	content string
+}
+
+func newTestNotifier(t *testing.T) (*Notifier, *[]sentNotification) {
+	logToTempDir(t)
+	old := config.Conf
+	config.Conf.NotifyOfflineFailures = 1
+	config.Conf.NotifyCooldown = 0
+	config.Conf.NotifyHysteresis = 1
+	t.Cleanup(func() {
+		config.Conf = old
+	})
+	sent := make([]sentNotification, 0)
+	return NewNotifier(func(title string, content string) {
+		sent = append(sent, sentNotification{title: title, content: content})
+	}), &sent
+}
+
+func newNotifyServer() *Server {
+	return NewServer(ServerConfig{Ip: "127.0.0.1", Port: 27015, Notify: true})
+}
+
+func setOnline(server *Server, serverName string, playerCount int64) {
+	server.state = ServerState{
+		Status: StatusOnline,
+		Info:   &Info{ServerName: serverName, PlayerCount: playerCount, MaxPlayers: 10},
+	}
+}
+
+// setOffline keeps the info of the last success like refresh does.
+func setOffline(server *Server) {
+	server.state.Status = StatusOffline
+	server.state.LastError = "timeout"
+	server.state.FailureCount++
+}
+
+func TestNotifierOffline(t *testing.T) {
+	n, sent := newTestNotifier(t)
+	server := newNotifyServer()
+
+	// never seen online
+	setOffline(server)
+	n.check(server)
+	if len(*sent) != 0 {
+		t.Fatalf("notified %v for a server never seen online", *sent)
+	}
+
+	setOnline(server, "s", 1)
+	n.check(server)
+	setOffline(server)
+	n.check(server)
+	n.check(server)
+	setOnline(server, "s", 1)
+	n.check(server)
+	want := []sentNotification{
+		{title: "s", content: "服务器离线：timeout"},
+		{title: "s", content: "服务器恢复在线"},
+	}
+	if !reflect.DeepEqual(*sent, want) {
+		t.Errorf("sent %v, want %v", *sent, want)
+	}
+}
+
+func TestNotifierSanitizesServerName(t *testing.T) {
+	n, sent := newTestNotifier(t)
+	server := newNotifyServer()
+	setOnline(server, "<b>my</b> server<script>alert(1)</script>", 1)
+	n.check(server)
+	setOnline(server, "<b>my</b> server<script>alert(1)</script>", 0)
+	n.check(server)
+
+	if len(*sent) != 1 || (*sent)[0].title != "my server" {
+		t.Errorf("sent %v, want the sanitized name", *sent)
+	}
+}
+
+func TestNotifierForget(t *testing.T) {
+	n, _ := newTestNotifier(t)
+	server := newNotifyServer()
+	setOnline(server, "s", 1)
+	n.check(server)
+
+	n.forget(server, ChangeAdded)
+	if len(n.states) != 1 {
+		t.Errorf("state dropped on add")
+	}
+	n.forget(server, ChangeUpdated)
+	if len(n.states) != 0 {
+		t.Errorf("state kept on update")
+	}
+	n.check(server)
+	n.forget(server, ChangeRemoved)
+	if len(n.states) != 0 {
+		t.Errorf("state kept on remove")
+	}
+}
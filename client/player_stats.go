@@ -0,0 +1,113 @@
+package client
+
+import (
+	"github.com/comoyi/steam-server-monitor/store"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlayerStats is the profile of a player aggregated over the sessions on all servers.
+type PlayerStats struct {
+	Name string `json:"name"`
+	// seconds
+	Playtime     int64 `json:"playtime"`
+	SessionCount int64 `json:"session_count"`
+	// unix time in seconds
+	FirstSeen int64    `json:"first_seen"`
+	LastSeen  int64    `json:"last_seen"`
+	Online    bool     `json:"online"`
+	Servers   []string `json:"servers"`
+}
+
+func (p *PlayerStats) add(session *store.Session, serverName string) {
+	p.Playtime += session.Duration
+	p.SessionCount++
+	if p.FirstSeen == 0 || session.StartTime < p.FirstSeen {
+		p.FirstSeen = session.StartTime
+	}
+	if session.EndTime > p.LastSeen {
+		p.LastSeen = session.EndTime
+	}
+	for _, s := range p.Servers {
+		if s == serverName {
+			return
+		}
+	}
+	p.Servers = append(p.Servers, serverName)
+}
+
+// GetPlayerStats returns the stats of the players whose name contains keyword, most played first.
+func GetPlayerStats(keyword string) ([]*PlayerStats, error) {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	match := func(name string) bool {
+		return keyword == "" || strings.Contains(strings.ToLower(name), keyword)
+	}
+
+	// sessions of removed servers are shown with the key
+	serverNames := make(map[string]string)
+	activeSessions := make(map[string][]*store.Session)
+	for _, server := range serverContainer.GetServers() {
+		key := historyKey(server)
+		conf := server.Config()
+		serverNames[key] = conf.DisplayName
+		if conf.DisplayName == "" {
+			serverNames[key] = conf.Address()
+		}
+		activeSessions[key] = sessionTracker.Active(key)
+	}
+	serverName := func(key string) string {
+		if name, ok := serverNames[key]; ok {
+			return name
+		}
+		return key
+	}
+
+	stats := make(map[string]*PlayerStats)
+	get := func(name string) *PlayerStats {
+		p, ok := stats[name]
+		if !ok {
+			p = &PlayerStats{
+				Name:    name,
+				Servers: make([]string, 0),
+			}
+			stats[name] = p
+		}
+		return p
+	}
+
+	if historyStore != nil {
+		all, err := historyStore.AllSessions(time.Unix(0, 0), time.Now().Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		for key, sessions := range all {
+			for _, session := range sessions {
+				if match(session.Name) {
+					get(session.Name).add(session, serverName(key))
+				}
+			}
+		}
+	}
+	for key, sessions := range activeSessions {
+		for _, session := range sessions {
+			if match(session.Name) {
+				p := get(session.Name)
+				p.add(session, serverName(key))
+				p.Online = true
+			}
+		}
+	}
+
+	list := make([]*PlayerStats, 0, len(stats))
+	for _, p := range stats {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Playtime != list[j].Playtime {
+			return list[i].Playtime > list[j].Playtime
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"container/heap"
+	"context"
+	"github.com/comoyi/steam-server-monitor/config"
+	"github.com/comoyi/steam-server-monitor/log"
+	"sync"
+	"time"
+)
+
+var defaultScheduler *Scheduler
+var defaultSchedulerOnce sync.Once
+
+func getScheduler() *Scheduler {
+	defaultSchedulerOnce.Do(func() {
+		defaultScheduler = NewScheduler(int(config.Conf.QueryWorkers), config.Conf.QueryRateLimit)
+	})
+	return defaultScheduler
+}
+
+type scheduleItem struct {
+	server *Server
+	ctx    context.Context
+	due    time.Time
+	// index in the queue, -1 while the query is in progress
+	index int
+}
+
+// scheduleQueue is a priority queue ordered by next due time.
+type scheduleQueue []*scheduleItem
+
+func (q scheduleQueue) Len() int {
+	return len(q)
+}
+
+func (q scheduleQueue) Less(i, j int) bool {
+	return q[i].due.Before(q[j].due)
+}
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x interface{}) {
+	item := x.(*scheduleItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
+
+// Scheduler drives the queries of all servers with a bounded number of workers.
+type Scheduler struct {
+	workers   int
+	rateLimit float64
+	queue     scheduleQueue
+	items     map[*Server]*scheduleItem
+	jobs      chan *scheduleItem
+	wake      chan struct{}
+	mu        sync.Mutex
+	startOnce sync.Once
+	// queries the server, replaced in tests
+	refresh func(ctx context.Context, server *Server)
+
+	lastDispatchTime time.Time
+}
+
+// NewScheduler creates a scheduler, rateLimit is the max refreshes per second, 0 means unlimited.
+func NewScheduler(workers int, rateLimit float64) *Scheduler {
+	if workers <= 0 {
+		workers = 8
+	}
+	return &Scheduler{
+		workers:   workers,
+		rateLimit: rateLimit,
+		queue:     make(scheduleQueue, 0),
+		items:     make(map[*Server]*scheduleItem),
+		jobs:      make(chan *scheduleItem),
+		wake:      make(chan struct{}, 1),
+		refresh:   refresh,
+	}
+}
+
+// Add schedules the first refresh of the server after delay, the server is refreshed until ctx is done or it is removed.
+func (sc *Scheduler) Add(ctx context.Context, server *Server, delay time.Duration) {
+	sc.startOnce.Do(sc.start)
+
+	sc.mu.Lock()
+	if old, ok := sc.items[server]; ok && old.index >= 0 {
+		heap.Remove(&sc.queue, old.index)
+	}
+	item := &scheduleItem{
+		server: server,
+		ctx:    ctx,
+		due:    time.Now().Add(delay),
+	}
+	sc.items[server] = item
+	heap.Push(&sc.queue, item)
+	sc.mu.Unlock()
+	sc.signal()
+}
+
+// Remove unschedules the server, a query in progress is not rescheduled.
+func (sc *Scheduler) Remove(server *Server) {
+	sc.mu.Lock()
+	item, ok := sc.items[server]
+	if ok {
+		delete(sc.items, server)
+		if item.index >= 0 {
+			heap.Remove(&sc.queue, item.index)
+		}
+	}
+	sc.mu.Unlock()
+	sc.signal()
+}
+
+func (sc *Scheduler) start() {
+	log.Debugf("Scheduler start, workers: %d, rateLimit: %v\n", sc.workers, sc.rateLimit)
+	for i := 0; i < sc.workers; i++ {
+		go sc.work()
+	}
+	go sc.dispatch()
+}
+
+func (sc *Scheduler) signal() {
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sc *Scheduler) dispatch() {
+	for {
+		sc.mu.Lock()
+		if len(sc.queue) == 0 {
+			sc.mu.Unlock()
+			<-sc.wake
+			continue
+		}
+		item := sc.queue[0]
+		wait := time.Until(item.due)
+		if wait > 0 {
+			sc.mu.Unlock()
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-sc.wake:
+			}
+			timer.Stop()
+			continue
+		}
+		heap.Pop(&sc.queue)
+		// registered before the lock is released so Server.Stop can wait for it
+		item.server.inflight.Add(1)
+		sc.mu.Unlock()
+
+		sc.waitRateLimit()
+		sc.jobs <- item
+	}
+}
+
+func (sc *Scheduler) waitRateLimit() {
+	if sc.rateLimit <= 0 {
+		return
+	}
+	gap := time.Duration(float64(time.Second) / sc.rateLimit)
+	if wait := time.Until(sc.lastDispatchTime.Add(gap)); wait > 0 {
+		time.Sleep(wait)
+	}
+	sc.lastDispatchTime = time.Now()
+}
+
+func (sc *Scheduler) work() {
+	for item := range sc.jobs {
+		if item.ctx.Err() == nil {
+			sc.refresh(item.ctx, item.server)
+		}
+		sc.reschedule(item)
+		item.server.inflight.Done()
+	}
+}
+
+func (sc *Scheduler) reschedule(item *scheduleItem) {
+	// servers failing repeatedly are polled less often
+	interval := item.server.Config().BackoffInterval(item.server.State().FailureCount)
+
+	sc.mu.Lock()
+	if sc.items[item.server] != item || item.ctx.Err() != nil {
+		sc.mu.Unlock()
+		return
+	}
+	item.due = time.Now().Add(interval)
+	heap.Push(&sc.queue, item)
+	sc.mu.Unlock()
+	sc.signal()
+}
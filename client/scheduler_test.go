@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"github.com/comoyi/steam-server-monitor/log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// logToTempDir keeps the log of a test out of the package directory.
+func logToTempDir(t *testing.T) {
+	log.SetPath(filepath.Join(t.TempDir(), "log.log"))
+	t.Cleanup(func() {
+		log.SetPath("log.log")
+	})
+}
+
+// refreshRecorder is a fake refresh which records the refreshed servers.
+type refreshRecorder struct {
+	refreshed chan *Server
+	times     []time.Time
+	mu        sync.Mutex
+}
+
+func newRefreshRecorder() *refreshRecorder {
+	return &refreshRecorder{refreshed: make(chan *Server, 100)}
+}
+
+func (r *refreshRecorder) refresh(ctx context.Context, server *Server) {
+	r.mu.Lock()
+	r.times = append(r.times, time.Now())
+	r.mu.Unlock()
+	r.refreshed <- server
+}
+
+func (r *refreshRecorder) next(t *testing.T) *Server {
+	select {
+	case server := <-r.refreshed:
+		return server
+	case <-time.After(time.Second):
+		t.Fatalf("no refresh in time")
+		return nil
+	}
+}
+
+func (r *refreshRecorder) none(t *testing.T, wait time.Duration) {
+	select {
+	case server := <-r.refreshed:
+		t.Errorf("unexpected refresh of %s", server.Config().Address())
+	case <-time.After(wait):
+	}
+}
+
+func newTestScheduler(t *testing.T, workers int, rateLimit float64) (*Scheduler, *refreshRecorder, context.Context) {
+	logToTempDir(t)
+	recorder := newRefreshRecorder()
+	sc := NewScheduler(workers, rateLimit)
+	sc.refresh = recorder.refresh
+	ctx, cancel := context.WithCancel(context.Background())
+	// the workers outlive the test, wait for them to leave the servers alone
+	t.Cleanup(func() {
+		cancel()
+		sc.mu.Lock()
+		servers := make([]*Server, 0, len(sc.items))
+		for server := range sc.items {
+			servers = append(servers, server)
+		}
+		sc.mu.Unlock()
+		for _, server := range servers {
+			sc.Remove(server)
+			server.inflight.Wait()
+		}
+	})
+	return sc, recorder, ctx
+}
+
+func newTestServer(port int64) *Server {
+	return NewServer(ServerConfig{Ip: "127.0.0.1", Port: port, Interval: 60})
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	sc, recorder, ctx := newTestScheduler(t, 1, 0)
+	a, b, c := newTestServer(1), newTestServer(2), newTestServer(3)
+	sc.Add(ctx, a, 60*time.Millisecond)
+	sc.Add(ctx, b, 20*time.Millisecond)
+	sc.Add(ctx, c, 40*time.Millisecond)
+
+	for i, want := range []*Server{b, c, a} {
+		if got := recorder.next(t); got != want {
+			t.Errorf("refresh %d = %s, want %s", i, got.Config().Address(), want.Config().Address())
+		}
+	}
+	// the next refreshes are an interval away
+	recorder.none(t, 100*time.Millisecond)
+}
+
+func TestSchedulerRateLimit(t *testing.T) {
+	sc, recorder, ctx := newTestScheduler(t, 4, 20)
+	for port := int64(1); port <= 4; port++ {
+		sc.Add(ctx, newTestServer(port), 0)
+	}
+	for i := 0; i < 4; i++ {
+		recorder.next(t)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	// 20 per second is one every 50ms, allow for the timer resolution
+	for i := 1; i < len(recorder.times); i++ {
+		if gap := recorder.times[i].Sub(recorder.times[i-1]); gap < 40*time.Millisecond {
+			t.Errorf("gap between refresh %d and %d is %v", i-1, i, gap)
+		}
+	}
+}
+
+func TestSchedulerAddReschedules(t *testing.T) {
+	sc, recorder, ctx := newTestScheduler(t, 2, 0)
+	server := newTestServer(1)
+	sc.Add(ctx, server, time.Hour)
+	// an edited server is added again and replaces the pending refresh
+	sc.Add(ctx, server, 10*time.Millisecond)
+
+	if got := recorder.next(t); got != server {
+		t.Errorf("refreshed %s", got.Config().Address())
+	}
+	recorder.none(t, 100*time.Millisecond)
+}
+
+func TestSchedulerRemove(t *testing.T) {
+	sc, recorder, ctx := newTestScheduler(t, 2, 0)
+	removed, kept := newTestServer(1), newTestServer(2)
+	sc.Add(ctx, removed, 20*time.Millisecond)
+	sc.Add(ctx, kept, 40*time.Millisecond)
+	sc.Remove(removed)
+
+	if got := recorder.next(t); got != kept {
+		t.Errorf("refreshed %s, want %s", got.Config().Address(), kept.Config().Address())
+	}
+	recorder.none(t, 100*time.Millisecond)
+}
+
+func TestServerStopWaitsForInflight(t *testing.T) {
+	logToTempDir(t)
+	started := make(chan struct{})
+	var finished int32
+	sc := NewScheduler(1, 0)
+	sc.refresh = func(ctx context.Context, server *Server) {
+		close(started)
+		<-ctx.Done()
+		// a query takes a while to notice the cancel
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	}
+	defaultSchedulerOnce.Do(func() {})
+	old := defaultScheduler
+	defaultScheduler = sc
+	t.Cleanup(func() {
+		defaultScheduler = old
+	})
+
+	server := newTestServer(1)
+	server.Start()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("no refresh in time")
+	}
+	server.Stop()
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Errorf("Stop returned before the refresh in progress")
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.queue) != 0 || len(sc.items) != 0 {
+		t.Errorf("stopped server is still scheduled, queue: %d, items: %d", len(sc.queue), len(sc.items))
+	}
+}
@@ -1,14 +1,19 @@
 package client
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/data/binding"
+	"github.com/comoyi/steam-server-monitor/config"
 	"github.com/comoyi/steam-server-monitor/log"
-	"github.com/comoyi/steam-server-monitor/util/timeutil"
-	"github.com/microcosm-cc/bluemonday"
 	"github.com/rumblefrog/go-a2s"
+	"math"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,9 +24,25 @@ func GetServerContainer() *ServerContainer {
 	return serverContainer
 }
 
+// Listener is called after a server has been refreshed.
+type Listener func(server *Server)
+
+type Change string
+
+const (
+	ChangeAdded   Change = "added"
+	ChangeUpdated Change = "updated"
+	ChangeRemoved Change = "removed"
+)
+
+// ChangeListener is called after a server has been added, updated or removed.
+type ChangeListener func(server *Server, change Change)
+
 type ServerContainer struct {
-	Servers []*Server
-	mu      sync.Mutex
+	Servers         []*Server
+	listeners       []Listener
+	changeListeners []ChangeListener
+	mu              sync.Mutex
 }
 
 func NewServerContainer() *ServerContainer {
@@ -33,87 +54,323 @@ func NewServerContainer() *ServerContainer {
 }
 
 func (sc *ServerContainer) GetServers() []*Server {
-	return sc.Servers
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	servers := make([]*Server, len(sc.Servers))
+	copy(servers, sc.Servers)
+	return servers
 }
 
-func (sc *ServerContainer) AddServer(server *Server) {
+func (sc *ServerContainer) AddListener(listener Listener) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.listeners = append(sc.listeners, listener)
+}
+
+func (sc *ServerContainer) notify(server *Server) {
+	sc.mu.Lock()
+	listeners := make([]Listener, len(sc.listeners))
+	copy(listeners, sc.listeners)
+	sc.mu.Unlock()
+	for _, listener := range listeners {
+		listener(server)
+	}
+}
+
+// GetServer returns the server with id, nil when not found.
+func (sc *ServerContainer) GetServer(id string) *Server {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for _, server := range sc.Servers {
+		if server.Config().Id == id {
+			return server
+		}
+	}
+	return nil
+}
+
+func (sc *ServerContainer) AddChangeListener(listener ChangeListener) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
+	sc.changeListeners = append(sc.changeListeners, listener)
+}
+
+func (sc *ServerContainer) notifyChange(server *Server, change Change) {
+	sc.mu.Lock()
+	listeners := make([]ChangeListener, len(sc.changeListeners))
+	copy(listeners, sc.changeListeners)
+	sc.mu.Unlock()
+	for _, listener := range listeners {
+		listener(server, change)
+	}
+}
+
+func (sc *ServerContainer) AddServer(server *Server) {
+	sc.mu.Lock()
 	sc.Servers = append(sc.Servers, server)
+	sc.mu.Unlock()
+	sc.notifyChange(server, ChangeAdded)
 }
 
+// UpdateServer applies conf to the server and restarts its polling.
+func (sc *ServerContainer) UpdateServer(server *Server, conf ServerConfig) {
+	server.Update(conf)
+	sc.notifyChange(server, ChangeUpdated)
+}
+
+// RemoveServer removes the server and stops its polling.
 func (sc *ServerContainer) RemoveServer(server *Server) {
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	removed := false
 	for i, s := range sc.Servers {
 		if s == server {
 			sc.Servers = append(sc.Servers[:i], sc.Servers[i+1:]...)
+			removed = true
 			break
 		}
 	}
+	sc.mu.Unlock()
+	server.Stop()
+	if removed {
+		sc.notifyChange(server, ChangeRemoved)
+	}
+}
+
+// ServerConfig is the editable settings of a server.
+type ServerConfig struct {
+	// stable identity, kept when the other settings change
+	Id          string
+	DisplayName string
+	Ip          string
+	Port        int64
+	Interval    int64
+	Remark      string
+	QueryRules  bool
+
+	// optional overrides of the global query settings, nil means use the global one
+	TimeoutMs          *int64
+	Retries            *int64
+	RetryBackoffMs     *int64
+	MaxBackoffInterval *int64
+
+	Notify                bool
+	NotifyPlayerThreshold int64
+}
+
+// ValidationError is an invalid field of a ServerConfig, the UI shows its own message for the field.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// Validate checks the settings the same way for the form and the api.
+func (c ServerConfig) Validate() error {
+	if strings.TrimSpace(c.Ip) == "" {
+		return &ValidationError{Field: "ip", Message: "ip is required"}
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return &ValidationError{Field: "port", Message: "port must be a number between 1 and 65535"}
+	}
+	if c.Interval <= 0 {
+		return &ValidationError{Field: "interval", Message: "interval must be greater than 0"}
+	}
+	optionals := []struct {
+		field   string
+		value   *int64
+		message string
+	}{
+		{"timeout_ms", c.TimeoutMs, "timeout_ms must not be negative"},
+		{"retries", c.Retries, "retries must not be negative"},
+		{"retry_backoff_ms", c.RetryBackoffMs, "retry_backoff_ms must not be negative"},
+		{"max_backoff_interval", c.MaxBackoffInterval, "max_backoff_interval must not be negative"},
+	}
+	for _, o := range optionals {
+		if o.value != nil && *o.value < 0 {
+			return &ValidationError{Field: o.field, Message: o.message}
+		}
+	}
+	if c.NotifyPlayerThreshold < 0 {
+		return &ValidationError{Field: "notify_player_threshold", Message: "notify_player_threshold must not be negative"}
+	}
+	return nil
+}
+
+func newServerId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (c ServerConfig) Address() string {
+	return net.JoinHostPort(c.Ip, strconv.FormatInt(c.Port, 10))
+}
+
+func (c ServerConfig) Timeout() time.Duration {
+	return time.Duration(overrideInt(c.TimeoutMs, config.Conf.QueryTimeoutMs)) * time.Millisecond
+}
+
+func (c ServerConfig) RetryCount() int64 {
+	return overrideInt(c.Retries, config.Conf.QueryRetries)
+}
+
+func (c ServerConfig) RetryBackoff() time.Duration {
+	return time.Duration(overrideInt(c.RetryBackoffMs, config.Conf.QueryRetryBackoffMs)) * time.Millisecond
+}
+
+// BackoffInterval returns the polling interval after failureCount consecutive failures,
+// it doubles on each further failure up to MaxBackoffInterval.
+func (c ServerConfig) BackoffInterval(failureCount int64) time.Duration {
+	interval := time.Duration(c.Interval) * time.Second
+	maxInterval := time.Duration(overrideInt(c.MaxBackoffInterval, config.Conf.MaxBackoffInterval)) * time.Second
+	if failureCount <= 1 || maxInterval <= interval {
+		return interval
+	}
+	for i := int64(1); i < failureCount && interval < maxInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxInterval {
+		interval = maxInterval
+	}
+	return interval
+}
+
+func overrideInt(value *int64, defaultValue int64) int64 {
+	if value != nil {
+		return *value
+	}
+	return defaultValue
+}
+
+// ServerState is the result of the latest queries of a server.
+type ServerState struct {
+	Info            *Info
+	Status          Status
+	LastUpdateTime  time.Time
+	LastSuccessTime time.Time
+	LastError       string
+	FailureCount    int64
+
+	// totals since the server was added, reset when the address changes
+	QueryCount    int64
+	QueryFailures int64
 }
 
 type Server struct {
-	DisplayName    string
-	Name           string
-	Ip             string
-	Port           int64
-	Interval       int64
-	IntervalTicker *time.Ticker
-	Remark         string
-	Info           *Info
-	ViewData       *ViewData
-	Container      *fyne.Container
-}
-
-func NewServer(displayName string, ip string, port int64, interval int64, remark string) *Server {
-	if interval <= 0 {
-		interval = 10
-	}
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	conf  ServerConfig
+	state ServerState
+	mu    sync.RWMutex
+
+	latencies []time.Duration
+
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+	runMu    sync.Mutex
+}
+
+func NewServer(conf ServerConfig) *Server {
+	if conf.Interval <= 0 {
+		conf.Interval = 10
+	}
+	if conf.Id == "" {
+		conf.Id = newServerId()
+	}
 	return &Server{
-		DisplayName:    displayName,
-		Ip:             ip,
-		Port:           port,
-		Interval:       interval,
-		IntervalTicker: ticker,
-		Remark:         remark,
+		conf: conf,
+		state: ServerState{
+			Status: StatusUnknown,
+		},
 	}
 }
 
-func (s *Server) Start() {
-	s.AsyncRefresh()
+func (s *Server) Config() ServerConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.conf
 }
 
-func (s *Server) AsyncRefresh() {
-	go func(server *Server) {
-		refresh(server)
-		for {
-			select {
-			case <-server.IntervalTicker.C:
-				refresh(server)
-			}
+func (s *Server) State() ServerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// Update applies new settings, a running server restarts polling so the change takes effect at once.
+// The id of the server is kept.
+func (s *Server) Update(conf ServerConfig) {
+	if conf.Interval <= 0 {
+		conf.Interval = 10
+	}
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	running := s.cancel != nil
+	if running {
+		s.stop()
+	}
+
+	s.mu.Lock()
+	conf.Id = s.conf.Id
+	if conf.Address() != s.conf.Address() {
+		// the old state belongs to another server
+		s.state = ServerState{
+			Status: StatusUnknown,
 		}
-	}(s)
+		s.latencies = nil
+	}
+	s.conf = conf
+	s.mu.Unlock()
+
+	if running {
+		s.start(0)
+	}
+}
+
+func (s *Server) Start() {
+	s.StartAfter(0)
 }
 
-func (s *Server) UpdateInterval(interval int64) {
-	s.Interval = interval
-	if s.IntervalTicker != nil {
-		s.IntervalTicker.Reset(time.Duration(interval) * time.Second)
+// StartAfter schedules the first refresh of the server after delay.
+func (s *Server) StartAfter(delay time.Duration) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.cancel != nil {
+		return
 	}
+	s.start(delay)
+}
+
+// Stop unschedules the server, cancels the in-flight query and waits for it to return.
+func (s *Server) Stop() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.cancel == nil {
+		return
+	}
+	s.stop()
+}
+
+func (s *Server) start(delay time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	getScheduler().Add(ctx, s, delay)
 }
 
-func (s *Server) getInfo() (*Info, error) {
-	return getInfo(s)
+func (s *Server) stop() {
+	getScheduler().Remove(s)
+	s.cancel()
+	s.inflight.Wait()
+	s.cancel = nil
 }
 
-type ViewData struct {
-	ServerName      binding.String
-	PlayerCount     binding.String
-	MaxDurationInfo binding.String
-	Remark          binding.String
-	PlayerInfos     binding.ExternalStringList
+func (s *Server) getInfo(ctx context.Context) (*Info, error) {
+	return getInfo(ctx, s.Config())
 }
 
 type Player struct {
@@ -122,86 +379,185 @@ type Player struct {
 }
 
 type Info struct {
-	ServerName  string    `json:"server_name"`
-	PlayerCount int64     `json:"player_count"`
-	Players     []*Player `json:"players"`
+	ServerName  string            `json:"server_name"`
+	Map         string            `json:"map"`
+	Folder      string            `json:"folder"`
+	Game        string            `json:"game"`
+	AppId       int64             `json:"app_id"`
+	GameId      uint64            `json:"game_id"`
+	SteamId     uint64            `json:"steam_id"`
+	PlayerCount int64             `json:"player_count"`
+	MaxPlayers  int64             `json:"max_players"`
+	Bots        int64             `json:"bots"`
+	ServerType  string            `json:"server_type"`
+	Os          string            `json:"os"`
+	Password    bool              `json:"password"`
+	Vac         bool              `json:"vac"`
+	Version     string            `json:"version"`
+	Keywords    string            `json:"keywords"`
+	Tags        []string          `json:"tags"`
+	GamePort    int64             `json:"game_port"`
+	Players     []*Player         `json:"players"`
+	Rules       map[string]string `json:"rules,omitempty"`
+
+	// round trip time of the A2S_INFO exchange and its stats over the latest queries
+	LatencyMs    float64 `json:"latency_ms"`
+	LatencyMinMs float64 `json:"latency_min_ms"`
+	LatencyAvgMs float64 `json:"latency_avg_ms"`
+	LatencyMaxMs float64 `json:"latency_max_ms"`
 }
 
-func refresh(server *Server) {
-	info, err := server.getInfo()
-	if err != nil {
-		return
-	}
-	server.Info = info
-	refreshUI(server)
+// IsFull reports whether the server reports no free slot.
+func (i *Info) IsFull() bool {
+	return i.MaxPlayers > 0 && i.PlayerCount >= i.MaxPlayers
 }
 
-func refreshUI(server *Server) {
-	if server == nil {
-		log.Warnf("refreshUI server is nil\n")
-		return
-	}
-	info := server.Info
-	infoJson, err := json.Marshal(info)
-	if err != nil {
-		log.Warnf("json.Marshal failed, err: %v\n", err)
+func refresh(ctx context.Context, server *Server) {
+	info, err := server.getInfo(ctx)
+	if ctx.Err() != nil {
+		// stopped or restarted while querying, the result is outdated
 		return
 	}
-	log.Debugf("infoJson: %s\n", infoJson)
 
-	if server.DisplayName != "" {
-		server.ViewData.ServerName.Set(fmt.Sprintf("服务器：%s", server.DisplayName))
+	server.mu.Lock()
+	state := &server.state
+	oldStatus := state.Status
+	state.LastUpdateTime = time.Now()
+	state.QueryCount++
+	var degradedErr *DegradedError
+	if err != nil && !errors.As(err, &degradedErr) {
+		state.Status = StatusOffline
+		state.LastError = err.Error()
+		state.FailureCount++
+		state.QueryFailures++
 	} else {
-		if info == nil {
-			server.ViewData.ServerName.Set(fmt.Sprintf("服务器：%s", "-"))
+		server.recordLatency(info)
+		state.Info = info
+		state.LastSuccessTime = time.Now()
+		state.FailureCount = 0
+		if degradedErr != nil {
+			state.Status = StatusDegraded
+			state.LastError = degradedErr.Error()
+		} else {
+			state.Status = StatusOnline
+			state.LastError = ""
 		}
 	}
-	server.ViewData.Remark.Set(fmt.Sprintf("备注：%s", server.Remark))
+	newStatus := state.Status
+	address := server.conf.Address()
+	server.mu.Unlock()
 
-	if info != nil {
-		var maxDuration int64 = 0
-		for _, p := range info.Players {
-			if p == nil {
-				continue
-			}
-			if p.Duration > maxDuration {
-				maxDuration = p.Duration
-			}
+	if newStatus != oldStatus {
+		log.Infof("server %s status changed %s -> %s\n", address, oldStatus, newStatus)
+	}
+	serverContainer.notify(server)
+}
+
+// recordLatency adds the latency of info to the rolling window and fills the stats of info, s.mu must be held.
+func (s *Server) recordLatency(info *Info) {
+	latency := time.Duration(info.LatencyMs * float64(time.Millisecond))
+	size := int(config.Conf.LatencyWindow)
+	if size <= 0 {
+		size = 1
+	}
+	s.latencies = append(s.latencies, latency)
+	if len(s.latencies) > size {
+		s.latencies = s.latencies[len(s.latencies)-size:]
+	}
+
+	minLatency, maxLatency := s.latencies[0], s.latencies[0]
+	var sum time.Duration = 0
+	for _, l := range s.latencies {
+		if l < minLatency {
+			minLatency = l
 		}
-		maxDurationFormatted := "-"
-		if info.PlayerCount > 0 {
-			maxDurationFormatted = timeutil.FormatDuration(maxDuration)
+		if l > maxLatency {
+			maxLatency = l
 		}
+		sum += l
+	}
+	info.LatencyMinMs = durationToMs(minLatency)
+	info.LatencyAvgMs = durationToMs(sum / time.Duration(len(s.latencies)))
+	info.LatencyMaxMs = durationToMs(maxLatency)
+}
 
-		serverNameFixed := ""
-		if server.DisplayName != "" {
-			serverNameFixed = server.DisplayName
-		} else {
-			serverNameFixed = bluemonday.StrictPolicy().Sanitize(info.ServerName)
-		}
-		server.ViewData.ServerName.Set(fmt.Sprintf("服务器：%s", serverNameFixed))
-		server.ViewData.PlayerCount.Set(fmt.Sprintf("在线人数：%d", info.PlayerCount))
-		server.ViewData.MaxDurationInfo.Set(fmt.Sprintf("最长在线：%s", maxDurationFormatted))
+func durationToMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*100) / 100
+}
 
-		playerInfoList := make([]string, 0)
-		for i, p := range info.Players {
-			if p == nil {
-				continue
-			}
-			nameStr := " " + p.Name
-			playerInfoList = append(playerInfoList, fmt.Sprintf("玩家%2d 连续在线 %s%s", i+1, timeutil.FormatDuration(p.Duration), nameStr))
+// getInfo queries the server and retries with exponential backoff on failure.
+func getInfo(ctx context.Context, conf ServerConfig) (*Info, error) {
+	retries := conf.RetryCount()
+	backoff := conf.RetryBackoff()
+	var degradedInfo *Info
+	var degradedErr *DegradedError
+	var err error
+	for attempt := int64(0); ; attempt++ {
+		var info *Info
+		info, err = queryInfo(ctx, conf.Address(), conf.Timeout(), conf.QueryRules)
+		if err == nil {
+			return info, nil
+		}
+		var e *DegradedError
+		if errors.As(err, &e) {
+			degradedInfo, degradedErr = info, e
 		}
+		if attempt >= retries || ctx.Err() != nil {
+			break
+		}
+		log.Debugf("query %s failed, retry after %v, attempt: %d, err: %v\n", conf.Address(), backoff, attempt+1, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if degradedInfo != nil {
+		return degradedInfo, degradedErr
+	}
+	return nil, err
+}
+
+// quickQueryTimeout bounds the whole one-off query, including players and rules.
+const quickQueryTimeout = 3 * time.Second
 
-		server.ViewData.PlayerInfos.Set(playerInfoList)
+// QueryOnce queries the server at address (host:port) once with rules and without retries,
+// the server does not need to be monitored and nothing is recorded.
+// The info is returned with a *DegradedError when only part of the queries succeeded.
+func QueryOnce(ctx context.Context, address string) (*Info, error) {
+	host, portRaw, err := net.SplitHostPort(strings.TrimSpace(address))
+	if err != nil {
+		return nil, &ValidationError{Field: "address", Message: "address must be host:port"}
+	}
+	port, err := strconv.ParseInt(portRaw, 10, 64)
+	if err != nil {
+		return nil, &ValidationError{Field: "port", Message: "port must be a number between 1 and 65535"}
+	}
+	var timeoutMs = quickQueryTimeout.Milliseconds()
+	var retries int64 = 0
+	conf := ServerConfig{
+		Ip:         host,
+		Port:       port,
+		Interval:   1,
+		QueryRules: true,
+		TimeoutMs:  &timeoutMs,
+		Retries:    &retries,
 	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, quickQueryTimeout)
+	defer cancel()
+	return getInfo(ctx, conf)
 }
 
-func getInfo(server *Server) (*Info, error) {
+func queryInfo(ctx context.Context, address string, timeout time.Duration, queryRules bool) (*Info, error) {
 	var err error
-	ip := server.Ip
-	port := server.Port
-	address := fmt.Sprintf("%s:%d", ip, port)
-	client, err := a2s.NewClient(address)
+	options := make([]func(*a2s.Client) error, 0)
+	if timeout > 0 {
+		options = append(options, a2s.TimeoutOption(timeout))
+	}
+	client, err := a2s.NewClient(address, options...)
 
 	if err != nil {
 		log.Warnf("NewClient failed, err: %v\n", err)
@@ -210,7 +566,20 @@ func getInfo(server *Server) (*Info, error) {
 
 	defer client.Close()
 
+	// a2s has no context support, closing the connection unblocks the pending read
+	queryDone := make(chan struct{})
+	defer close(queryDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = client.Close()
+		case <-queryDone:
+		}
+	}()
+
+	queryStartTime := time.Now()
 	serverInfo, err := client.QueryInfo()
+	latency := time.Since(queryStartTime)
 
 	if err != nil {
 		log.Warnf("QueryInfo failed, err: %v\n", err)
@@ -224,40 +593,93 @@ func getInfo(server *Server) (*Info, error) {
 	}
 	log.Debugf("serverInfoJson: %s\n", serverInfoJson)
 
-	var serverName = ""
-	serverName = serverInfo.Name
+	var degradedErr *DegradedError
 
+	var players = make([]*Player, 0)
+	var playerCount int64 = 0
 	playerInfo, err := client.QueryPlayer()
-
 	if err != nil {
+		// fall back to the player count reported by A2S_INFO
 		log.Warnf("QueryPlayer failed, err: %v\n", err)
-		return nil, err
-	}
+		degradedErr = &DegradedError{Query: "QueryPlayer", Err: err}
+		playerCount = int64(serverInfo.Players)
+	} else {
+		playerInfoJson, err := json.Marshal(playerInfo)
+		if err != nil {
+			log.Warnf("Marshal failed, err: %v\n", err)
+			return nil, err
+		}
+		log.Debugf("playerInfoJson: %s\n", playerInfoJson)
 
-	playerInfoJson, err := json.Marshal(playerInfo)
-	if err != nil {
-		log.Warnf("Marshal failed, err: %v\n", err)
-		return nil, err
+		for _, p := range playerInfo.Players {
+			if p == nil {
+				continue
+			}
+			player := &Player{
+				Name:     p.Name,
+				Duration: int64(p.Duration),
+			}
+			players = append(players, player)
+		}
+		playerCount = int64(len(players))
 	}
-	log.Debugf("playerInfoJson: %s\n", playerInfoJson)
 
-	var players = make([]*Player, 0)
-	for _, p := range playerInfo.Players {
-		if p == nil {
-			continue
+	info := &Info{
+		ServerName:  serverInfo.Name,
+		Map:         serverInfo.Map,
+		Folder:      serverInfo.Folder,
+		Game:        serverInfo.Game,
+		AppId:       int64(serverInfo.ID),
+		PlayerCount: playerCount,
+		MaxPlayers:  int64(serverInfo.MaxPlayers),
+		Bots:        int64(serverInfo.Bots),
+		ServerType:  serverInfo.ServerType.String(),
+		Os:          serverInfo.ServerOS.String(),
+		Password:    serverInfo.Visibility,
+		Vac:         serverInfo.VAC,
+		Version:     serverInfo.Version,
+		Tags:        make([]string, 0),
+		Players:     players,
+		LatencyMs:   durationToMs(latency),
+	}
+	if ext := serverInfo.ExtendedServerInfo; ext != nil {
+		info.GamePort = int64(ext.Port)
+		info.SteamId = ext.SteamID
+		info.GameId = ext.GameID
+		if ext.GameID > 0 {
+			// a more accurate AppID is present in the low 24 bits of GameID
+			info.AppId = int64(ext.GameID & 0xFFFFFF)
 		}
-		player := &Player{
-			Name:     p.Name,
-			Duration: int64(p.Duration),
+		info.Keywords = ext.Keywords
+		info.Tags = parseTags(ext.Keywords)
+	}
+
+	if queryRules {
+		// many servers do not answer A2S_RULES, keep the info without rules
+		rulesInfo, err := client.QueryRules()
+		if err != nil {
+			log.Warnf("QueryRules failed, err: %v\n", err)
+			if degradedErr == nil {
+				degradedErr = &DegradedError{Query: "QueryRules", Err: err}
+			}
+		} else {
+			info.Rules = rulesInfo.Rules
 		}
-		players = append(players, player)
 	}
+	if degradedErr != nil {
+		return info, degradedErr
+	}
+	return info, nil
+}
 
-	var playerCount int64 = 0
-	playerCount = int64(len(players))
-	return &Info{
-		ServerName:  serverName,
-		PlayerCount: playerCount,
-		Players:     players,
-	}, nil
+func parseTags(keywords string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(keywords, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags
 }
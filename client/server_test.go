@@ -0,0 +1,60 @@
+package client
+
+import (
+	"github.com/comoyi/steam-server-monitor/config"
+	"testing"
+	"time"
+)
+
+func TestBackoffInterval(t *testing.T) {
+	old := config.Conf.MaxBackoffInterval
+	config.Conf.MaxBackoffInterval = 300
+	t.Cleanup(func() {
+		config.Conf.MaxBackoffInterval = old
+	})
+	maxInterval := func(v int64) *int64 {
+		return &v
+	}
+
+	tests := []struct {
+		name         string
+		interval     int64
+		maxInterval  *int64
+		failureCount int64
+		want         time.Duration
+	}{
+		{name: "no failure", interval: 10, failureCount: 0, want: 10 * time.Second},
+		{name: "first failure", interval: 10, failureCount: 1, want: 10 * time.Second},
+		{name: "second failure", interval: 10, failureCount: 2, want: 20 * time.Second},
+		{name: "doubles", interval: 10, failureCount: 4, want: 80 * time.Second},
+		{name: "capped by default", interval: 10, failureCount: 6, want: 300 * time.Second},
+		{name: "many failures", interval: 10, failureCount: 1000, want: 300 * time.Second},
+		{name: "capped by server", interval: 10, maxInterval: maxInterval(60), failureCount: 4, want: 60 * time.Second},
+		{name: "max below interval", interval: 120, maxInterval: maxInterval(60), failureCount: 4, want: 120 * time.Second},
+		{name: "backoff disabled", interval: 10, maxInterval: maxInterval(0), failureCount: 4, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		conf := ServerConfig{Interval: tt.interval, MaxBackoffInterval: tt.maxInterval}
+		if got := conf.BackoffInterval(tt.failureCount); got != tt.want {
+			t.Errorf("%s: BackoffInterval(%d) = %v, want %v", tt.name, tt.failureCount, got, tt.want)
+		}
+	}
+}
+
+func TestServerConfigAddress(t *testing.T) {
+	tests := []struct {
+		ip   string
+		port int64
+		want string
+	}{
+		{ip: "127.0.0.1", port: 27015, want: "127.0.0.1:27015"},
+		{ip: "example.com", port: 2457, want: "example.com:2457"},
+		{ip: "::1", port: 27015, want: "[::1]:27015"},
+		{ip: "2001:db8::1", port: 27015, want: "[2001:db8::1]:27015"},
+	}
+	for _, tt := range tests {
+		if got := (ServerConfig{Ip: tt.ip, Port: tt.port}).Address(); got != tt.want {
+			t.Errorf("Address of %s %d = %s, want %s", tt.ip, tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package client
+
+import (
+	"github.com/comoyi/steam-server-monitor/log"
+	"github.com/comoyi/steam-server-monitor/store"
+	"sort"
+	"sync"
+	"time"
+)
+
+var sessionTracker = NewSessionTracker()
+
+func GetSessionTracker() *SessionTracker {
+	return sessionTracker
+}
+
+type activeSession struct {
+	name         string
+	startTime    time.Time
+	lastSeenTime time.Time
+	lastDuration int64
+}
+
+func (a *activeSession) toSession(endTime time.Time) *store.Session {
+	return &store.Session{
+		Name:      a.name,
+		StartTime: a.startTime.Unix(),
+		EndTime:   endTime.Unix(),
+		Duration:  int64(endTime.Sub(a.startTime) / time.Second),
+	}
+}
+
+type serverSessions struct {
+	sessions   []*activeSession
+	updateTime time.Time
+}
+
+// SessionTracker infers joins and leaves by diffing consecutive player snapshots of a server.
+// A player is the same session as long as the name matches and the connected duration keeps growing.
+type SessionTracker struct {
+	servers map[string]*serverSessions
+	mu      sync.Mutex
+}
+
+func NewSessionTracker() *SessionTracker {
+	return &SessionTracker{
+		servers: make(map[string]*serverSessions),
+	}
+}
+
+// Update applies a snapshot taken at now and returns the sessions which ended since the previous snapshot.
+func (t *SessionTracker) Update(serverKey string, players []*Player, now time.Time) []*store.Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ss, ok := t.servers[serverKey]
+	if !ok {
+		ss = &serverSessions{
+			updateTime: now,
+		}
+		t.servers[serverKey] = ss
+	}
+	elapsed := int64(now.Sub(ss.updateTime) / time.Second)
+	// tolerate the rounding of the reported duration and the time between query and now
+	slack := elapsed / 5
+	if slack < 5 {
+		slack = 5
+	}
+
+	candidates := make(map[string][]*activeSession)
+	for _, a := range ss.sessions {
+		candidates[a.name] = append(candidates[a.name], a)
+	}
+
+	sorted := make([]*Player, 0, len(players))
+	for _, p := range players {
+		// connecting players have no name yet
+		if p == nil || p.Name == "" {
+			continue
+		}
+		sorted = append(sorted, p)
+	}
+	// the longest connected players take the oldest sessions first
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	next := make([]*activeSession, 0, len(sorted))
+	for _, p := range sorted {
+		list := candidates[p.Name]
+		matched := -1
+		for i, a := range list {
+			// some games always report 0, then only the name is compared
+			continuous := p.Duration+slack >= a.lastDuration+elapsed || (p.Duration == 0 && a.lastDuration == 0)
+			if continuous && (matched < 0 || a.lastDuration > list[matched].lastDuration) {
+				matched = i
+			}
+		}
+		if matched >= 0 {
+			a := list[matched]
+			candidates[p.Name] = append(list[:matched], list[matched+1:]...)
+			a.lastSeenTime = now
+			a.lastDuration = p.Duration
+			next = append(next, a)
+			continue
+		}
+		next = append(next, &activeSession{
+			name:         p.Name,
+			startTime:    now.Add(-time.Duration(p.Duration) * time.Second),
+			lastSeenTime: now,
+			lastDuration: p.Duration,
+		})
+	}
+
+	ended := make([]*store.Session, 0)
+	for _, list := range candidates {
+		for _, a := range list {
+			ended = append(ended, a.toSession(a.lastSeenTime))
+		}
+	}
+	ss.sessions = next
+	ss.updateTime = now
+	return ended
+}
+
+// Active returns the sessions in progress of the server.
+func (t *SessionTracker) Active(serverKey string) []*store.Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sessions := make([]*store.Session, 0)
+	ss, ok := t.servers[serverKey]
+	if !ok {
+		return sessions
+	}
+	for _, a := range ss.sessions {
+		sessions = append(sessions, a.toSession(a.lastSeenTime))
+	}
+	return sessions
+}
+
+func (t *SessionTracker) Remove(serverKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.servers, serverKey)
+}
+
+func trackSessions(server *Server) {
+	state := server.State()
+	info := state.Info
+	if state.Status == StatusOffline || info == nil {
+		return
+	}
+	if len(info.Players) == 0 && info.PlayerCount > 0 {
+		// the player query failed, the snapshot is unknown
+		return
+	}
+	key := historyKey(server)
+	sessionAddressesMu.Lock()
+	sessionAddresses[key] = server.Config().Address()
+	sessionAddressesMu.Unlock()
+	ended := sessionTracker.Update(key, info.Players, state.LastSuccessTime)
+	saveSessions(key, ended)
+}
+
+// the address the sessions in progress were seen on by server key, an edit of the address ends them
+var sessionAddresses = make(map[string]string)
+var sessionAddressesMu sync.Mutex
+
+// endSessions ends the sessions in progress of a removed server or a server moved to another address.
+func endSessions(server *Server, change Change) {
+	key := historyKey(server)
+	sessionAddressesMu.Lock()
+	seenAddress, seen := sessionAddresses[key]
+	moved := change == ChangeUpdated && seen && seenAddress != server.Config().Address()
+	if change == ChangeRemoved || moved {
+		delete(sessionAddresses, key)
+	}
+	sessionAddressesMu.Unlock()
+	if change != ChangeRemoved && !moved {
+		return
+	}
+
+	saveSessions(key, sessionTracker.Update(key, nil, time.Now()))
+	sessionTracker.Remove(key)
+}
+
+func saveSessions(serverKey string, sessions []*store.Session) {
+	if historyStore == nil {
+		return
+	}
+	for _, session := range sessions {
+		err := historyStore.AddSession(serverKey, session)
+		if err != nil {
+			log.Warnf("AddSession failed, err: %v\n", err)
+		}
+	}
+}
+
+// ActivityEvent is a join or leave of a player.
+type ActivityEvent struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// unix time in seconds
+	Time int64 `json:"time"`
+	// seconds, the session length on leave, the current length on join of a session in progress
+	Duration int64 `json:"duration"`
+}
+
+const (
+	ActivityJoin  = "join"
+	ActivityLeave = "leave"
+)
+
+// GetActivity returns the joins and leaves on the server since from, newest first.
+func GetActivity(server *Server, from time.Time) ([]*ActivityEvent, error) {
+	key := historyKey(server)
+	sessions := make([]*store.Session, 0)
+	if historyStore != nil {
+		stored, err := historyStore.Sessions(key, from, time.Now().Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, stored...)
+	}
+
+	events := make([]*ActivityEvent, 0)
+	for _, s := range sessions {
+		if s.StartTime >= from.Unix() {
+			events = append(events, &ActivityEvent{Type: ActivityJoin, Name: s.Name, Time: s.StartTime, Duration: s.Duration})
+		}
+		events = append(events, &ActivityEvent{Type: ActivityLeave, Name: s.Name, Time: s.EndTime, Duration: s.Duration})
+	}
+	for _, s := range sessionTracker.Active(key) {
+		if s.StartTime >= from.Unix() {
+			events = append(events, &ActivityEvent{Type: ActivityJoin, Name: s.Name, Time: s.StartTime, Duration: s.Duration})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time > events[j].Time
+	})
+	return events, nil
+}
+
+func initSessions() {
+	serverContainer.AddListener(trackSessions)
+	serverContainer.AddChangeListener(endSessions)
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"fmt"
+	"github.com/comoyi/steam-server-monitor/store"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// sessionNames returns the sessions as sorted name:duration strings.
+func sessionNames(sessions []*store.Session) []string {
+	names := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		names = append(names, fmt.Sprintf("%s:%d", s.Name, s.Duration))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func players(players ...interface{}) []*Player {
+	list := make([]*Player, 0)
+	for i := 0; i < len(players); i += 2 {
+		list = append(list, &Player{Name: players[i].(string), Duration: int64(players[i+1].(int))})
+	}
+	return list
+}
+
+func TestSessionTrackerUpdate(t *testing.T) {
+	type step struct {
+		// seconds since the first snapshot
+		at      int64
+		players []*Player
+		ended   []string
+		active  []string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "continues while the duration grows",
+			steps: []step{
+				{at: 0, players: players("A", 100), ended: []string{}, active: []string{"A:100"}},
+				{at: 10, players: players("A", 110), ended: []string{}, active: []string{"A:110"}},
+				{at: 20, players: players("A", 118), ended: []string{}, active: []string{"A:120"}},
+			},
+		},
+		{
+			name: "ends when the player leaves",
+			steps: []step{
+				{at: 0, players: players("A", 100, "B", 5), ended: []string{}, active: []string{"A:100", "B:5"}},
+				{at: 10, players: players("B", 15), ended: []string{"A:100"}, active: []string{"B:15"}},
+				{at: 20, players: players(), ended: []string{"B:15"}, active: []string{}},
+			},
+		},
+		{
+			name: "a duration reset is a reconnect",
+			steps: []step{
+				{at: 0, players: players("A", 500), ended: []string{}, active: []string{"A:500"}},
+				{at: 10, players: players("A", 3), ended: []string{"A:500"}, active: []string{"A:3"}},
+			},
+		},
+		{
+			name: "duplicate names keep their own sessions",
+			steps: []step{
+				{at: 0, players: players("P", 300, "P", 20), ended: []string{}, active: []string{"P:20", "P:300"}},
+				{at: 10, players: players("P", 30, "P", 310), ended: []string{}, active: []string{"P:30", "P:310"}},
+				// the short one is still there, the long one left
+				{at: 20, players: players("P", 40), ended: []string{"P:310"}, active: []string{"P:40"}},
+			},
+		},
+		{
+			name: "duplicate names where one reconnects",
+			steps: []step{
+				{at: 0, players: players("P", 300, "P", 200), ended: []string{}, active: []string{"P:200", "P:300"}},
+				{at: 10, players: players("P", 310, "P", 1), ended: []string{"P:200"}, active: []string{"P:1", "P:310"}},
+			},
+		},
+		{
+			name: "empty names are ignored",
+			steps: []step{
+				{at: 0, players: players("", 10, "A", 10), ended: []string{}, active: []string{"A:10"}},
+				{at: 10, players: players("", 20), ended: []string{"A:10"}, active: []string{}},
+			},
+		},
+		{
+			name: "games reporting no duration match by name",
+			steps: []step{
+				{at: 0, players: players("A", 0), ended: []string{}, active: []string{"A:0"}},
+				{at: 10, players: players("A", 0), ended: []string{}, active: []string{"A:10"}},
+				{at: 20, players: players("B", 0), ended: []string{"A:10"}, active: []string{"B:0"}},
+			},
+		},
+		{
+			name: "nil players are skipped",
+			steps: []step{
+				{at: 0, players: []*Player{nil, {Name: "A", Duration: 10}}, ended: []string{}, active: []string{"A:10"}},
+			},
+		},
+	}
+	start := time.Unix(1700000000, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewSessionTracker()
+			for i, step := range tt.steps {
+				ended := sessionNames(tracker.Update("s", step.players, start.Add(time.Duration(step.at)*time.Second)))
+				if !reflect.DeepEqual(ended, step.ended) {
+					t.Errorf("step %d: ended %v, want %v", i, ended, step.ended)
+				}
+				if active := sessionNames(tracker.Active("s")); !reflect.DeepEqual(active, step.active) {
+					t.Errorf("step %d: active %v, want %v", i, active, step.active)
+				}
+			}
+		})
+	}
+}
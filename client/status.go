@@ -0,0 +1,27 @@
+package client
+
+import "fmt"
+
+type Status string
+
+const (
+	StatusUnknown  Status = "unknown"
+	StatusOnline   Status = "online"
+	StatusOffline  Status = "offline"
+	StatusDegraded Status = "degraded"
+)
+
+// DegradedError is returned by getInfo together with the info when the server answered
+// A2S_INFO but a later query failed.
+type DegradedError struct {
+	Query string
+	Err   error
+}
+
+func (e *DegradedError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Query, e.Err)
+}
+
+func (e *DegradedError) Unwrap() error {
+	return e.Err
+}
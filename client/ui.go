@@ -1,9 +1,15 @@
+//go:build !headless
+
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
 	"fyne.io/fyne/v2/dialog"
@@ -13,9 +19,15 @@ import (
 	"github.com/comoyi/steam-server-monitor/log"
 	"github.com/comoyi/steam-server-monitor/theme"
 	"github.com/comoyi/steam-server-monitor/util/dialogutil"
+	"github.com/comoyi/steam-server-monitor/util/timeutil"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/spf13/viper"
+	"image/color"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +39,94 @@ var serverListPanel *fyne.Container
 
 var serverListPanelScroll *container.Scroll
 
+type ViewData struct {
+	ServerName      binding.String
+	PlayerCount     binding.String
+	MaxDurationInfo binding.String
+	MapInfo         binding.String
+	LatencyInfo     binding.String
+	Remark          binding.String
+	PlayerInfos     binding.ExternalStringList
+}
+
+// ServerView holds the UI state of a server panel.
+type ServerView struct {
+	ViewData    *ViewData
+	StatusBadge *canvas.Text
+	Container   *fyne.Container
+	// reloads the history chart when it is expanded
+	ReloadChart func()
+}
+
+var serverViews = make(map[*Server]*ServerView)
+var serverViewsMu sync.Mutex
+
+func getServerView(server *Server) *ServerView {
+	serverViewsMu.Lock()
+	defer serverViewsMu.Unlock()
+	return serverViews[server]
+}
+
+func setServerView(server *Server, view *ServerView) {
+	serverViewsMu.Lock()
+	defer serverViewsMu.Unlock()
+	serverViews[server] = view
+}
+
+func removeServerView(server *Server) *ServerView {
+	serverViewsMu.Lock()
+	defer serverViewsMu.Unlock()
+	view := serverViews[server]
+	delete(serverViews, server)
+	return view
+}
+
+func Start() {
+	log.Debugf("Client start\n")
+
+	initUI()
+
+	initHistory()
+	defer closeHistory()
+	initSessions()
+	initNotifier(func(title string, content string) {
+		myApp.SendNotification(fyne.NewNotification(title, content))
+	})
+
+	loadServers()
+
+	serverContainer.AddListener(refreshUI)
+	// servers may also be managed through the api
+	serverContainer.AddChangeListener(onServerChange)
+
+	go func() {
+		run()
+	}()
+
+	w.ShowAndRun()
+}
+
+func onServerChange(server *Server, change Change) {
+	switch change {
+	case ChangeAdded:
+		bind(server)
+	case ChangeUpdated:
+		refreshUI(server)
+	case ChangeRemoved:
+		if view := removeServerView(server); view != nil {
+			serverListPanel.Remove(view.Container)
+		}
+	}
+}
+
+func run() {
+	servers := serverContainer.GetServers()
+	for _, server := range servers {
+		bind(server)
+	}
+	startServers(servers)
+}
+
 func initUI() {
 	initMainWindow()
 	initMenu()
@@ -63,7 +163,13 @@ func initMenu() {
 	addMenuItem := fyne.NewMenuItem("添加服务器", func() {
 		showAddUI()
 	})
-	firstMenu := fyne.NewMenu("操作", addMenuItem)
+	playerStatsMenuItem := fyne.NewMenuItem("玩家统计", func() {
+		showPlayerStatsUI()
+	})
+	apiSettingsMenuItem := fyne.NewMenuItem("API 设置", func() {
+		showApiSettingsUI()
+	})
+	firstMenu := fyne.NewMenu("操作", addMenuItem, playerStatsMenuItem, apiSettingsMenuItem)
 	helpMenuItem := fyne.NewMenuItem("关于", func() {
 		content := container.NewVBox()
 		appInfo := widget.NewLabel(appName)
@@ -87,7 +193,7 @@ func initMenu() {
 }
 
 func initToolBar() *fyne.Container {
-	cBar := container.NewGridWithColumns(2)
+	cBar := container.NewGridWithColumns(4)
 
 	addBtn := widget.NewButtonWithIcon("", theme2.ContentAddIcon(), func() {
 		showAddUI()
@@ -119,6 +225,16 @@ func initToolBar() *fyne.Container {
 	})
 	cBar.Add(saveBtn)
 
+	playerStatsBtn := widget.NewButtonWithIcon("玩家", theme2.AccountIcon(), func() {
+		showPlayerStatsUI()
+	})
+	cBar.Add(playerStatsBtn)
+
+	quickQueryBtn := widget.NewButtonWithIcon("查询", theme2.SearchIcon(), func() {
+		showQuickQueryUI()
+	})
+	cBar.Add(quickQueryBtn)
+
 	return cBar
 }
 
@@ -132,6 +248,26 @@ func showEditUI(server *Server) {
 
 var serverFormWindow fyne.Window
 
+// validationMessages are the messages of the invalid fields shown to the user.
+var validationMessages = map[string]string{
+	"ip":                      "请输入IP",
+	"port":                    "请输入正确的端口",
+	"interval":                "请输入合适的间隔",
+	"timeout_ms":              "请输入正确的超时",
+	"retries":                 "请输入正确的重试次数",
+	"retry_backoff_ms":        "请输入正确的重试间隔",
+	"max_backoff_interval":    "请输入正确的最大退避间隔",
+	"notify_player_threshold": "请输入正确的人数提醒",
+	"address":                 "请输入正确的地址",
+}
+
+func validationMessage(err *ValidationError) string {
+	if message, ok := validationMessages[err.Field]; ok {
+		return message
+	}
+	return err.Message
+}
+
 func showServerFormUI(isEdit bool, server *Server) {
 	title := "添加服务器"
 	if isEdit {
@@ -140,6 +276,10 @@ func showServerFormUI(isEdit bool, server *Server) {
 		}
 		title = "编辑服务器"
 	}
+	var conf ServerConfig
+	if isEdit {
+		conf = server.Config()
+	}
 
 	if serverFormWindow != nil {
 		// prevent error exit on android
@@ -155,13 +295,20 @@ func showServerFormUI(isEdit bool, server *Server) {
 	c3 := container.NewAdaptiveGrid(2)
 	c4 := container.NewAdaptiveGrid(2)
 	c5 := container.NewAdaptiveGrid(2)
+	c6 := container.NewAdaptiveGrid(2)
+	c7 := container.NewAdaptiveGrid(2)
+	c8 := container.NewAdaptiveGrid(2)
+	c9 := container.NewAdaptiveGrid(2)
+	c10 := container.NewAdaptiveGrid(2)
+	c11 := container.NewAdaptiveGrid(2)
+	c12 := container.NewAdaptiveGrid(2)
 
 	displayNameLabel := widget.NewLabel("显示名称")
 	var displayNameEntry *widget.Entry
 	displayNameEntry = widget.NewEntry()
 	displayNameEntry.SetPlaceHolder("默认为服务器名称")
 	if isEdit {
-		displayNameEntry.SetText(server.DisplayName)
+		displayNameEntry.SetText(conf.DisplayName)
 	}
 
 	ipLabel := widget.NewLabel("IP")
@@ -169,7 +316,7 @@ func showServerFormUI(isEdit bool, server *Server) {
 	ipEntry = widget.NewEntry()
 	ipEntry.SetPlaceHolder("127.0.0.1")
 	if isEdit {
-		ipEntry.SetText(server.Ip)
+		ipEntry.SetText(conf.Ip)
 	}
 
 	portLabel := widget.NewLabel("端口")
@@ -183,14 +330,14 @@ func showServerFormUI(isEdit bool, server *Server) {
 	portEntry = widget.NewEntry()
 	portEntry.SetPlaceHolder("2457")
 	if isEdit {
-		portEntry.SetText(strconv.FormatInt(server.Port, 10))
+		portEntry.SetText(strconv.FormatInt(conf.Port, 10))
 	}
 	intervalLabel := widget.NewLabel("刷新间隔（秒）")
 	intervalEntry := widget.NewEntry()
 	intervalEntry.SetPlaceHolder("10")
 	intervalText := "10"
 	if isEdit {
-		intervalText = strconv.FormatInt(server.Interval, 10)
+		intervalText = strconv.FormatInt(conf.Interval, 10)
 	}
 	intervalEntry.Text = intervalText
 
@@ -198,20 +345,43 @@ func showServerFormUI(isEdit bool, server *Server) {
 	var remarkEntry *widget.Entry
 	remarkEntry = widget.NewEntry()
 	if isEdit {
-		remarkEntry.SetText(server.Remark)
+		remarkEntry.SetText(conf.Remark)
+	}
+
+	queryRulesLabel := widget.NewLabel("查询规则")
+	queryRulesCheck := widget.NewCheck("", nil)
+	if isEdit {
+		queryRulesCheck.SetChecked(conf.QueryRules)
+	}
+
+	timeoutLabel := widget.NewLabel("超时（毫秒）")
+	timeoutEntry := newOptionalIntEntry(conf.TimeoutMs, config.Conf.QueryTimeoutMs)
+	retriesLabel := widget.NewLabel("重试次数")
+	retriesEntry := newOptionalIntEntry(conf.Retries, config.Conf.QueryRetries)
+	retryBackoffLabel := widget.NewLabel("重试间隔（毫秒）")
+	retryBackoffEntry := newOptionalIntEntry(conf.RetryBackoffMs, config.Conf.QueryRetryBackoffMs)
+	maxBackoffIntervalLabel := widget.NewLabel("最大退避间隔（秒）")
+	maxBackoffIntervalEntry := newOptionalIntEntry(conf.MaxBackoffInterval, config.Conf.MaxBackoffInterval)
+
+	notifyLabel := widget.NewLabel("桌面通知")
+	notifyCheck := widget.NewCheck("", nil)
+	notifyPlayerThresholdLabel := widget.NewLabel("人数提醒")
+	notifyPlayerThresholdEntry := widget.NewEntry()
+	notifyPlayerThresholdEntry.SetPlaceHolder("0为不提醒")
+	if isEdit {
+		notifyCheck.SetChecked(conf.Notify)
+		if conf.NotifyPlayerThreshold > 0 {
+			notifyPlayerThresholdEntry.SetText(strconv.FormatInt(conf.NotifyPlayerThreshold, 10))
+		}
 	}
 
 	btnText := "添加"
 	if isEdit {
 		btnText = "保存"
 	}
-	displayName := displayNameEntry.Text
+	displayName := conf.DisplayName
 	submitBtn := widget.NewButton(btnText, func() {
 		ip := ipEntry.Text
-		if ip == "" {
-			dialogutil.ShowInformation("提示", "请输入IP", serverFormWindow)
-			return
-		}
 
 		portVal := portEntry.Text
 		if portVal == "" {
@@ -223,10 +393,6 @@ func showServerFormUI(isEdit bool, server *Server) {
 			dialogutil.ShowInformation("提示", "请输入正确的端口", serverFormWindow)
 			return
 		}
-		if port < 0 {
-			dialogutil.ShowInformation("提示", "请输入正确的端口", serverFormWindow)
-			return
-		}
 
 		intervalVal := intervalEntry.Text
 		if intervalVal == "" {
@@ -238,30 +404,70 @@ func showServerFormUI(isEdit bool, server *Server) {
 			dialogutil.ShowInformation("提示", "请输入正确的间隔", serverFormWindow)
 			return
 		}
-		if interval <= 0 {
-			dialogutil.ShowInformation("提示", "请输入合适的间隔", serverFormWindow)
+
+		remark := remarkEntry.Text
+		queryRules := queryRulesCheck.Checked
+
+		timeoutMs, err := parseOptionalInt(timeoutEntry.Text)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "请输入正确的超时", serverFormWindow)
+			return
+		}
+		retries, err := parseOptionalInt(retriesEntry.Text)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "请输入正确的重试次数", serverFormWindow)
+			return
+		}
+		retryBackoffMs, err := parseOptionalInt(retryBackoffEntry.Text)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "请输入正确的重试间隔", serverFormWindow)
+			return
+		}
+		maxBackoffInterval, err := parseOptionalInt(maxBackoffIntervalEntry.Text)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "请输入正确的最大退避间隔", serverFormWindow)
 			return
 		}
 
-		remark := remarkEntry.Text
+		notify := notifyCheck.Checked
+		notifyPlayerThreshold, err := parseOptionalInt(notifyPlayerThresholdEntry.Text)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "请输入正确的人数提醒", serverFormWindow)
+			return
+		}
+
+		newConf := ServerConfig{
+			DisplayName: displayNameEntry.Text,
+			Ip:          ip,
+			Port:        port,
+			Interval:    interval,
+			Remark:      remark,
+			QueryRules:  queryRules,
+
+			TimeoutMs:          timeoutMs,
+			Retries:            retries,
+			RetryBackoffMs:     retryBackoffMs,
+			MaxBackoffInterval: maxBackoffInterval,
 
+			Notify: notify,
+		}
+		if notifyPlayerThreshold != nil {
+			newConf.NotifyPlayerThreshold = *notifyPlayerThreshold
+		}
+		var validationErr *ValidationError
+		if err := newConf.Validate(); errors.As(err, &validationErr) {
+			dialogutil.ShowInformation("提示", validationMessage(validationErr), serverFormWindow)
+			return
+		}
 		if isEdit {
-			server.DisplayName = displayName
-			server.Ip = ip
-			server.Port = port
-			server.UpdateInterval(interval)
-			server.Remark = remark
-			refreshUI(server)
+			serverContainer.UpdateServer(server, newConf)
 		} else {
-			newServer := NewServer(displayName, ip, port, interval, remark)
+			newServer := NewServer(newConf)
 			serverContainer.AddServer(newServer)
-			bind(newServer)
 			newServer.Start()
 		}
 
-		resetServerConfig()
-
-		err = config.SaveConfig()
+		err = SaveServers()
 		if err != nil {
 			dialogutil.ShowInformation("提示", "保存失败", w)
 			return
@@ -276,16 +482,12 @@ func showServerFormUI(isEdit bool, server *Server) {
 		dialog.NewCustomConfirm("提示", "确定", "取消", widget.NewLabel(fmt.Sprintf("确定删除吗\n%s", displayName)), func(b bool) {
 			if b {
 				serverContainer.RemoveServer(server)
-				resetServerConfig()
-				err := config.SaveConfig()
+				err := SaveServers()
 				if err != nil {
 					dialogutil.ShowInformation("提示", "保存失败", serverFormWindow)
 					return
 				}
 
-				// remove UI container
-				serverListPanel.Remove(server.Container)
-
 				serverFormWindow.Close()
 			}
 		}, serverFormWindow).Show()
@@ -304,11 +506,32 @@ func showServerFormUI(isEdit bool, server *Server) {
 	c4.Add(intervalEntry)
 	c5.Add(remarkLabel)
 	c5.Add(remarkEntry)
+	c6.Add(queryRulesLabel)
+	c6.Add(queryRulesCheck)
+	c7.Add(timeoutLabel)
+	c7.Add(timeoutEntry)
+	c8.Add(retriesLabel)
+	c8.Add(retriesEntry)
+	c9.Add(retryBackoffLabel)
+	c9.Add(retryBackoffEntry)
+	c10.Add(maxBackoffIntervalLabel)
+	c10.Add(maxBackoffIntervalEntry)
+	c11.Add(notifyLabel)
+	c11.Add(notifyCheck)
+	c12.Add(notifyPlayerThresholdLabel)
+	c12.Add(notifyPlayerThresholdEntry)
 	c.Add(c1)
 	c.Add(c2)
 	c.Add(c3)
 	c.Add(c4)
 	c.Add(c5)
+	c.Add(c6)
+	c.Add(c7)
+	c.Add(c8)
+	c.Add(c9)
+	c.Add(c10)
+	c.Add(c11)
+	c.Add(c12)
 	cop1 := container.NewGridWithColumns(2)
 	cop2 := container.NewVBox()
 	cop3 := container.NewVBox()
@@ -322,32 +545,99 @@ func showServerFormUI(isEdit bool, server *Server) {
 	serverFormWindow.Show()
 }
 
+// newOptionalIntEntry creates an entry for an optional setting, the placeholder shows the global value used when empty.
+func newOptionalIntEntry(value *int64, defaultValue int64) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder(fmt.Sprintf("默认 %d", defaultValue))
+	if value != nil {
+		entry.SetText(strconv.FormatInt(*value, 10))
+	}
+	return entry
+}
+
+func parseOptionalInt(text string) (*int64, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if value < 0 {
+		return nil, fmt.Errorf("negative value: %d", value)
+	}
+	return &value, nil
+}
+
 func bind(server *Server) {
+	if getServerView(server) != nil {
+		return
+	}
+	conf := server.Config()
 	serverName := binding.NewString()
 	displayName := "-"
-	if server.DisplayName != "" {
-		displayName = server.DisplayName
+	if conf.DisplayName != "" {
+		displayName = conf.DisplayName
 	}
 	serverName.Set(fmt.Sprintf("服务器：%s", displayName))
 	playerCount := binding.NewString()
 	playerCount.Set(fmt.Sprintf("在线人数：%s", "-"))
 	maxDurationInfo := binding.NewString()
 	maxDurationInfo.Set(fmt.Sprintf("最长在线：%s", "-"))
+	mapInfo := binding.NewString()
+	mapInfo.Set(fmt.Sprintf("地图：%s", "-"))
+	latencyInfo := binding.NewString()
+	latencyInfo.Set(fmt.Sprintf("延迟：%s", "-"))
 	remarkInfo := binding.NewString()
-	remarkInfo.Set(fmt.Sprintf("备注：%s", server.Remark))
+	remarkInfo.Set(fmt.Sprintf("备注：%s", conf.Remark))
 
 	dataList := binding.BindStringList(&[]string{})
 
-	server.ViewData = &ViewData{
-		ServerName:      serverName,
-		PlayerCount:     playerCount,
-		MaxDurationInfo: maxDurationInfo,
-		Remark:          remarkInfo,
-		PlayerInfos:     dataList,
+	statusBadge := canvas.NewText("", theme2.DisabledColor())
+	statusBadge.TextStyle = fyne.TextStyle{Bold: true}
+	updateStatusBadge(statusBadge, server.State().Status)
+
+	chart := newHistoryChart()
+	chartContainer := container.NewHBox()
+	chartContainer.Hide()
+	chartRange := chartRanges[0]
+	var chartRangeMu sync.Mutex
+	reloadChart := func() {
+		if !chartContainer.Visible() {
+			return
+		}
+		chartRangeMu.Lock()
+		duration := chartRange.duration
+		chartRangeMu.Unlock()
+		go func() {
+			// whole seconds keep the buckets of the store aligned with the ones of the chart
+			to := time.Now().Truncate(time.Second)
+			from := to.Add(-duration)
+			samples, err := GetHistoryPoints(server, from, to, chartBuckets)
+			if err != nil {
+				log.Warnf("Load history failed, err: %v\n", err)
+				return
+			}
+			chart.SetData(samples, from, to)
+		}()
 	}
 
 	panelContainer := container.NewVBox()
-	server.Container = panelContainer
+	setServerView(server, &ServerView{
+		ViewData: &ViewData{
+			ServerName:      serverName,
+			PlayerCount:     playerCount,
+			MaxDurationInfo: maxDurationInfo,
+			MapInfo:         mapInfo,
+			LatencyInfo:     latencyInfo,
+			Remark:          remarkInfo,
+			PlayerInfos:     dataList,
+		},
+		StatusBadge: statusBadge,
+		Container:   panelContainer,
+		ReloadChart: reloadChart,
+	})
 
 	var detailContainer *fyne.Container
 
@@ -368,28 +658,56 @@ func bind(server *Server) {
 		showEditUI(server)
 	})
 	editBtn.SetIcon(theme2.DocumentCreateIcon())
+	infoBtn := widget.NewButtonWithIcon("", theme2.InfoIcon(), func() {
+		showServerDetailUI(server)
+	})
+	rulesBtn := widget.NewButtonWithIcon("", theme2.ListIcon(), func() {
+		showServerRulesUI(server)
+	})
+	activityBtn := widget.NewButtonWithIcon("", theme2.AccountIcon(), func() {
+		showServerActivityUI(server)
+	})
+	chartBtn := widget.NewButtonWithIcon("", theme2.HistoryIcon(), func() {
+		if chartContainer.Visible() {
+			chartContainer.Hide()
+		} else {
+			chartContainer.Show()
+			reloadChart()
+		}
+	})
 
 	overviewContainer := container.NewHBox()
 	b1 := container.NewVBox()
 	overviewContainer.Add(b1)
 	b2 := container.NewHBox()
 	b3 := container.NewHBox()
+	b6 := container.NewHBox()
 	detailContainer = container.NewHBox()
 	detailContainer.Hide()
 	b7 := container.NewHBox()
 	b1.Add(b2)
 	b1.Add(b3)
+	b1.Add(b6)
 	b1.Add(detailContainer)
+	b1.Add(chartContainer)
 	b1.Add(b7)
 	b4 := container.NewVBox()
 	b5 := container.NewVBox()
+	b8 := container.NewVBox()
 	b3.Add(toggleBtn)
 	b3.Add(b4)
+	b3.Add(b8)
 	b3.Add(b5)
 	b2.Add(editBtn)
+	b2.Add(infoBtn)
+	b2.Add(rulesBtn)
+	b2.Add(chartBtn)
+	b2.Add(activityBtn)
+	b2.Add(container.NewCenter(statusBadge))
 	b2.Add(widget.NewLabelWithData(serverName))
 	b4.Add(widget.NewLabelWithData(playerCount))
 	b5.Add(widget.NewLabelWithData(maxDurationInfo))
+	b8.Add(widget.NewLabelWithData(latencyInfo))
 
 	list := widget.NewListWithData(dataList, func() fyne.CanvasObject {
 		return widget.NewLabel("")
@@ -409,9 +727,39 @@ func bind(server *Server) {
 	detailListContainer := container.NewGridWrap(fyne.NewSize(320, 190))
 	detailListContainer.Add(scroll)
 
+	b6.Add(container.NewGridWrap(fyne.NewSize(40, 40)))
+	b6.Add(widget.NewLabelWithData(mapInfo))
+
 	detailContainer.Add(container.NewGridWrap(fyne.NewSize(40, 40)))
 	detailContainer.Add(detailListContainer)
-	if server.Remark != "" {
+
+	chartRangeOptions := make([]string, 0, len(chartRanges))
+	for _, r := range chartRanges {
+		chartRangeOptions = append(chartRangeOptions, r.name)
+	}
+	chartRangeRadio := widget.NewRadioGroup(chartRangeOptions, func(selected string) {
+		for _, r := range chartRanges {
+			if r.name == selected {
+				chartRangeMu.Lock()
+				chartRange = r
+				chartRangeMu.Unlock()
+				reloadChart()
+				break
+			}
+		}
+	})
+	chartRangeRadio.Horizontal = true
+	chartRangeRadio.SetSelected(chartRange.name)
+	chartRangeRadio.Required = true
+	chartBox := container.NewVBox()
+	chartBox.Add(chartRangeRadio)
+	chartBox.Add(container.NewGridWrap(fyne.NewSize(320, 150), chart))
+	if historyStore == nil {
+		chartBox.Add(widget.NewLabel("未开启历史数据"))
+	}
+	chartContainer.Add(container.NewGridWrap(fyne.NewSize(40, 40)))
+	chartContainer.Add(chartBox)
+	if conf.Remark != "" {
 		b7.Add(container.NewGridWrap(fyne.NewSize(40, 40)))
 		b7.Add(widget.NewLabelWithData(remarkInfo))
 	}
@@ -421,16 +769,583 @@ func bind(server *Server) {
 	serverListPanel.Refresh()
 }
 
-func resetServerConfig() {
-	serverConfig := make([]map[string]interface{}, 0)
-	for _, server := range serverContainer.GetServers() {
-		serverConfig = append(serverConfig, map[string]interface{}{
-			"display_name": server.DisplayName,
-			"ip":           server.Ip,
-			"port":         server.Port,
-			"interval":     server.Interval,
-			"remark":       server.Remark,
-		})
+func refreshUI(server *Server) {
+	if server == nil {
+		log.Warnf("refreshUI server is nil\n")
+		return
+	}
+	view := getServerView(server)
+	if view == nil {
+		log.Warnf("refreshUI server view is nil\n")
+		return
+	}
+	viewData := view.ViewData
+	conf := server.Config()
+	state := server.State()
+	updateStatusBadge(view.StatusBadge, state.Status)
+	if view.ReloadChart != nil {
+		view.ReloadChart()
+	}
+	info := state.Info
+	infoJson, err := json.Marshal(info)
+	if err != nil {
+		log.Warnf("json.Marshal failed, err: %v\n", err)
+		return
+	}
+	log.Debugf("infoJson: %s\n", infoJson)
+
+	if conf.DisplayName != "" {
+		viewData.ServerName.Set(fmt.Sprintf("服务器：%s", conf.DisplayName))
+	} else {
+		if info == nil {
+			viewData.ServerName.Set(fmt.Sprintf("服务器：%s", "-"))
+		}
 	}
-	viper.Set("servers", serverConfig)
+	viewData.Remark.Set(fmt.Sprintf("备注：%s", conf.Remark))
+
+	if info != nil {
+		var maxDuration int64 = 0
+		for _, p := range info.Players {
+			if p == nil {
+				continue
+			}
+			if p.Duration > maxDuration {
+				maxDuration = p.Duration
+			}
+		}
+		maxDurationFormatted := "-"
+		if info.PlayerCount > 0 {
+			maxDurationFormatted = timeutil.FormatDuration(maxDuration)
+		}
+
+		serverNameFixed := ""
+		if conf.DisplayName != "" {
+			serverNameFixed = conf.DisplayName
+		} else {
+			serverNameFixed = bluemonday.StrictPolicy().Sanitize(info.ServerName)
+		}
+		viewData.ServerName.Set(fmt.Sprintf("服务器：%s", serverNameFixed))
+		viewData.PlayerCount.Set(fmt.Sprintf("在线人数：%s", formatPlayerCount(info)))
+		viewData.MaxDurationInfo.Set(fmt.Sprintf("最长在线：%s", maxDurationFormatted))
+		mapName := "-"
+		if info.Map != "" {
+			mapName = info.Map
+		}
+		viewData.MapInfo.Set(fmt.Sprintf("地图：%s", mapName))
+		viewData.LatencyInfo.Set(fmt.Sprintf("延迟：%s", formatLatency(info.LatencyMs)))
+
+		playerInfoList := make([]string, 0)
+		for i, p := range info.Players {
+			if p == nil {
+				continue
+			}
+			nameStr := " " + p.Name
+			playerInfoList = append(playerInfoList, fmt.Sprintf("玩家%2d 连续在线 %s%s", i+1, timeutil.FormatDuration(p.Duration), nameStr))
+		}
+
+		viewData.PlayerInfos.Set(playerInfoList)
+	}
+}
+
+var statusTexts = map[Status]string{
+	StatusUnknown:  "未知",
+	StatusOnline:   "在线",
+	StatusOffline:  "离线",
+	StatusDegraded: "异常",
+}
+
+func formatStatus(status Status) string {
+	if text, ok := statusTexts[status]; ok {
+		return text
+	}
+	return statusTexts[StatusUnknown]
+}
+
+func updateStatusBadge(badge *canvas.Text, status Status) {
+	var c color.Color
+	switch status {
+	case StatusOnline:
+		c = color.NRGBA{R: 0x43, G: 0xa0, B: 0x47, A: 0xff}
+	case StatusDegraded:
+		c = color.NRGBA{R: 0xfb, G: 0x8c, B: 0x00, A: 0xff}
+	case StatusOffline:
+		c = theme2.ErrorColor()
+	default:
+		c = theme2.DisabledColor()
+	}
+	badge.Text = fmt.Sprintf("[%s]", formatStatus(status))
+	badge.Color = c
+	badge.Refresh()
+}
+
+func formatPlayerCount(info *Info) string {
+	if info.MaxPlayers <= 0 {
+		return strconv.FormatInt(info.PlayerCount, 10)
+	}
+	s := fmt.Sprintf("%d/%d", info.PlayerCount, info.MaxPlayers)
+	if info.IsFull() {
+		s += "（已满）"
+	}
+	return s
+}
+
+type chartRangeOption struct {
+	name     string
+	duration time.Duration
+}
+
+var chartRanges = []chartRangeOption{
+	{name: "1小时", duration: time.Hour},
+	{name: "24小时", duration: 24 * time.Hour},
+	{name: "7天", duration: 7 * 24 * time.Hour},
+	{name: "30天", duration: 30 * 24 * time.Hour},
+}
+
+func formatLatency(ms float64) string {
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+var serverDetailWindow fyne.Window
+
+func showServerDetailUI(server *Server) {
+	if serverDetailWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			serverDetailWindow.Close()
+		}
+	}
+	serverDetailWindow = myApp.NewWindow("服务器详情")
+
+	c := container.NewVBox()
+	conf := server.Config()
+	state := server.State()
+
+	lastSuccessTime := "-"
+	if !state.LastSuccessTime.IsZero() {
+		lastSuccessTime = state.LastSuccessTime.Format("2006-01-02 15:04:05")
+	}
+	lastError := "-"
+	if state.LastError != "" {
+		lastError = state.LastError
+	}
+	rows := [][2]string{
+		{"ID", conf.Id},
+		{"状态", formatStatus(state.Status)},
+		{"最后成功时间", lastSuccessTime},
+		{"连续失败次数", strconv.FormatInt(state.FailureCount, 10)},
+		{"最后错误", lastError},
+	}
+
+	info := state.Info
+	if info == nil {
+		addDetailRows(c, rows)
+		c.Add(widget.NewLabel("暂无数据"))
+		serverDetailWindow.SetContent(c)
+		serverDetailWindow.Show()
+		return
+	}
+
+	yesNo := func(b bool) string {
+		if b {
+			return "是"
+		}
+		return "否"
+	}
+	rows = append(rows, [][2]string{
+		{"服务器名称", bluemonday.StrictPolicy().Sanitize(info.ServerName)},
+		{"地址", conf.Address()},
+		{"游戏端口", strconv.FormatInt(info.GamePort, 10)},
+		{"地图", info.Map},
+		{"游戏", info.Game},
+		{"目录", info.Folder},
+		{"AppID", strconv.FormatInt(info.AppId, 10)},
+		{"GameID", strconv.FormatUint(info.GameId, 10)},
+		{"在线人数", formatPlayerCount(info)},
+		{"延迟", formatLatency(info.LatencyMs)},
+		{"延迟（最小/平均/最大）", fmt.Sprintf("%s / %s / %s", formatLatency(info.LatencyMinMs), formatLatency(info.LatencyAvgMs), formatLatency(info.LatencyMaxMs))},
+		{"机器人", strconv.FormatInt(info.Bots, 10)},
+		{"服务器类型", info.ServerType},
+		{"系统", info.Os},
+		{"需要密码", yesNo(info.Password)},
+		{"VAC", yesNo(info.Vac)},
+		{"版本", info.Version},
+		{"标签", strings.Join(info.Tags, ", ")},
+	}...)
+	addDetailRows(c, rows)
+
+	serverDetailWindow.SetContent(container.NewVScroll(c))
+	serverDetailWindow.Resize(fyne.NewSize(400, 600))
+	serverDetailWindow.Show()
+}
+
+func addDetailRows(c *fyne.Container, rows [][2]string) {
+	for _, row := range rows {
+		rowContainer := container.NewAdaptiveGrid(2)
+		rowContainer.Add(widget.NewLabel(row[0]))
+		valueLabel := widget.NewLabel(row[1])
+		valueLabel.Wrapping = fyne.TextWrapWord
+		rowContainer.Add(valueLabel)
+		c.Add(rowContainer)
+	}
+}
+
+var serverRulesWindow fyne.Window
+
+func showServerRulesUI(server *Server) {
+	if serverRulesWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			serverRulesWindow.Close()
+		}
+	}
+	serverRulesWindow = myApp.NewWindow("服务器规则")
+
+	var rules map[string]string
+	if info := server.State().Info; info != nil {
+		rules = info.Rules
+	}
+	if len(rules) == 0 {
+		tip := "暂无数据"
+		if !server.Config().QueryRules {
+			tip = "未开启查询规则"
+		}
+		serverRulesWindow.SetContent(container.NewVBox(widget.NewLabel(tip)))
+		serverRulesWindow.Show()
+		return
+	}
+
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filtered := names
+	table := widget.NewTable(func() (int, int) {
+		return len(filtered), 2
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.TableCellID, obj fyne.CanvasObject) {
+		o := obj.(*widget.Label)
+		if id.Row >= len(filtered) {
+			o.SetText("")
+			return
+		}
+		name := filtered[id.Row]
+		if id.Col == 0 {
+			o.SetText(name)
+		} else {
+			o.SetText(rules[name])
+		}
+	})
+	table.SetColumnWidth(0, 180)
+	table.SetColumnWidth(1, 200)
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("搜索")
+	searchEntry.OnChanged = func(keyword string) {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		filtered = make([]string, 0, len(names))
+		for _, name := range names {
+			if keyword == "" || strings.Contains(strings.ToLower(name), keyword) || strings.Contains(strings.ToLower(rules[name]), keyword) {
+				filtered = append(filtered, name)
+			}
+		}
+		table.Refresh()
+	}
+
+	serverRulesWindow.SetContent(container.NewBorder(searchEntry, nil, nil, nil, table))
+	serverRulesWindow.Resize(fyne.NewSize(400, 600))
+	serverRulesWindow.Show()
+}
+
+var serverActivityWindow fyne.Window
+
+func showServerActivityUI(server *Server) {
+	if serverActivityWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			serverActivityWindow.Close()
+		}
+	}
+	serverActivityWindow = myApp.NewWindow("玩家动态")
+
+	activityList := binding.BindStringList(&[]string{})
+	list := widget.NewListWithData(activityList, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(item binding.DataItem, obj fyne.CanvasObject) {
+		obj.(*widget.Label).Bind(item.(binding.String))
+	})
+
+	load := func(duration time.Duration) {
+		events, err := GetActivity(server, time.Now().Add(-duration))
+		if err != nil {
+			log.Warnf("GetActivity failed, err: %v\n", err)
+			return
+		}
+		lines := make([]string, 0, len(events))
+		for _, e := range events {
+			t := time.Unix(e.Time, 0).Format("01-02 15:04")
+			if e.Type == ActivityJoin {
+				lines = append(lines, fmt.Sprintf("%s 加入 %s", t, e.Name))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s 离开 %s 在线 %s", t, e.Name, timeutil.FormatDuration(e.Duration)))
+			}
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "暂无数据")
+		}
+		_ = activityList.Set(lines)
+	}
+
+	rangeOptions := []chartRangeOption{
+		{name: "24小时", duration: 24 * time.Hour},
+		{name: "7天", duration: 7 * 24 * time.Hour},
+		{name: "30天", duration: 30 * 24 * time.Hour},
+	}
+	names := make([]string, 0, len(rangeOptions))
+	for _, r := range rangeOptions {
+		names = append(names, r.name)
+	}
+	rangeRadio := widget.NewRadioGroup(names, func(selected string) {
+		for _, r := range rangeOptions {
+			if r.name == selected {
+				go load(r.duration)
+				break
+			}
+		}
+	})
+	rangeRadio.Horizontal = true
+	rangeRadio.Required = true
+	rangeRadio.SetSelected(rangeOptions[0].name)
+
+	serverActivityWindow.SetContent(container.NewBorder(rangeRadio, nil, nil, nil, list))
+	serverActivityWindow.Resize(fyne.NewSize(400, 600))
+	serverActivityWindow.Show()
+}
+
+var playerStatsWindow fyne.Window
+
+func showPlayerStatsUI() {
+	if playerStatsWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			playerStatsWindow.Close()
+		}
+	}
+	playerStatsWindow = myApp.NewWindow("玩家统计")
+
+	headers := []string{"玩家", "总时长", "次数", "首次", "最近", "服务器"}
+	var stats []*PlayerStats
+	var mu sync.Mutex
+	table := widget.NewTable(func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(stats) + 1, len(headers)
+	}, func() fyne.CanvasObject {
+		return widget.NewLabel("")
+	}, func(id widget.TableCellID, obj fyne.CanvasObject) {
+		o := obj.(*widget.Label)
+		if id.Row == 0 {
+			o.TextStyle = fyne.TextStyle{Bold: true}
+			o.SetText(headers[id.Col])
+			return
+		}
+		o.TextStyle = fyne.TextStyle{}
+		mu.Lock()
+		defer mu.Unlock()
+		if id.Row > len(stats) {
+			o.SetText("")
+			return
+		}
+		p := stats[id.Row-1]
+		text := ""
+		switch id.Col {
+		case 0:
+			text = p.Name
+			if p.Online {
+				text = fmt.Sprintf("%s（在线）", p.Name)
+			}
+		case 1:
+			text = timeutil.FormatDuration(p.Playtime)
+		case 2:
+			text = fmt.Sprintf("%d", p.SessionCount)
+		case 3:
+			text = time.Unix(p.FirstSeen, 0).Format("2006-01-02 15:04")
+		case 4:
+			text = time.Unix(p.LastSeen, 0).Format("2006-01-02 15:04")
+		case 5:
+			text = strings.Join(p.Servers, ", ")
+		}
+		o.SetText(text)
+	})
+	columnWidths := []float32{160, 100, 50, 140, 140, 200}
+	for i, width := range columnWidths {
+		table.SetColumnWidth(i, width)
+	}
+
+	load := func(keyword string) {
+		list, err := GetPlayerStats(keyword)
+		if err != nil {
+			log.Warnf("GetPlayerStats failed, err: %v\n", err)
+			return
+		}
+		mu.Lock()
+		stats = list
+		mu.Unlock()
+		table.Refresh()
+	}
+
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("搜索玩家")
+	searchEntry.OnChanged = func(keyword string) {
+		go load(keyword)
+	}
+	go load("")
+
+	playerStatsWindow.SetContent(container.NewBorder(searchEntry, nil, nil, nil, table))
+	playerStatsWindow.Resize(fyne.NewSize(800, 600))
+	playerStatsWindow.Show()
+}
+
+var apiSettingsWindow fyne.Window
+
+func showApiSettingsUI() {
+	if apiSettingsWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			apiSettingsWindow.Close()
+		}
+	}
+	apiSettingsWindow = myApp.NewWindow("API 设置")
+
+	c := container.NewVBox()
+	c1 := container.NewAdaptiveGrid(2)
+	c2 := container.NewAdaptiveGrid(2)
+	c3 := container.NewAdaptiveGrid(2)
+	apiSettings := config.GetApiSettings()
+
+	enableLabel := widget.NewLabel("启用 API")
+	enableCheck := widget.NewCheck("", nil)
+	enableCheck.SetChecked(apiSettings.Enable)
+
+	bindLabel := widget.NewLabel("监听地址")
+	bindEntry := widget.NewEntry()
+	bindEntry.SetPlaceHolder("为空时监听所有网卡")
+	bindEntry.SetText(apiSettings.Bind)
+
+	portLabel := widget.NewLabel("端口")
+	portEntry := widget.NewEntry()
+	portEntry.SetPlaceHolder("9091")
+	portEntry.SetText(strconv.FormatInt(apiSettings.Port, 10))
+
+	submitBtn := widget.NewButtonWithIcon("保存", theme2.DocumentSaveIcon(), func() {
+		port, err := strconv.ParseInt(strings.TrimSpace(portEntry.Text), 10, 64)
+		if err != nil || port <= 0 || port > 65535 {
+			dialogutil.ShowInformation("提示", "请输入正确的端口", apiSettingsWindow)
+			return
+		}
+		err = config.SetApi(enableCheck.Checked, strings.TrimSpace(bindEntry.Text), port)
+		if err != nil {
+			dialogutil.ShowInformation("提示", "保存失败", apiSettingsWindow)
+			return
+		}
+		apiSettingsWindow.Close()
+	})
+
+	c1.Add(enableLabel)
+	c1.Add(enableCheck)
+	c2.Add(bindLabel)
+	c2.Add(bindEntry)
+	c3.Add(portLabel)
+	c3.Add(portEntry)
+	c.Add(c1)
+	c.Add(c2)
+	c.Add(c3)
+	c.Add(submitBtn)
+
+	apiSettingsWindow.SetContent(c)
+	apiSettingsWindow.Show()
+}
+
+var quickQueryWindow fyne.Window
+
+// showQuickQueryUI queries a server once without adding it.
+func showQuickQueryUI() {
+	if quickQueryWindow != nil {
+		// prevent error exit on android
+		if runtime.GOOS != "android" {
+			quickQueryWindow.Close()
+		}
+	}
+	quickQueryWindow = myApp.NewWindow("快速查询")
+
+	result := container.NewVBox()
+	addressEntry := widget.NewEntry()
+	addressEntry.SetPlaceHolder("127.0.0.1:2457")
+
+	var queryBtn *widget.Button
+	queryBtn = widget.NewButtonWithIcon("查询", theme2.SearchIcon(), func() {
+		address := strings.TrimSpace(addressEntry.Text)
+		if address == "" {
+			dialogutil.ShowInformation("提示", "请输入地址", quickQueryWindow)
+			return
+		}
+		queryBtn.Disable()
+		queryBtn.SetText("查询中...")
+		go func() {
+			defer func() {
+				queryBtn.SetText("查询")
+				queryBtn.Enable()
+			}()
+			info, err := QueryOnce(context.Background(), address)
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				dialogutil.ShowInformation("提示", validationMessage(validationErr), quickQueryWindow)
+				return
+			}
+			result.RemoveAll()
+			if info == nil {
+				result.Add(widget.NewLabel(fmt.Sprintf("查询失败：%v", err)))
+				return
+			}
+			status := StatusOnline
+			if err != nil {
+				status = StatusDegraded
+			}
+			rows := [][2]string{
+				{"状态", formatStatus(status)},
+				{"服务器名称", bluemonday.StrictPolicy().Sanitize(info.ServerName)},
+				{"地图", info.Map},
+				{"游戏", info.Game},
+				{"在线人数", formatPlayerCount(info)},
+				{"延迟", formatLatency(info.LatencyMs)},
+				{"版本", info.Version},
+				{"标签", strings.Join(info.Tags, ", ")},
+			}
+			if err != nil {
+				rows = append(rows, [2]string{"错误", err.Error()})
+			}
+			for i, p := range info.Players {
+				if p == nil {
+					continue
+				}
+				rows = append(rows, [2]string{fmt.Sprintf("玩家%2d", i+1), fmt.Sprintf("%s %s", timeutil.FormatDuration(p.Duration), p.Name)})
+			}
+			names := make([]string, 0, len(info.Rules))
+			for name := range info.Rules {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				rows = append(rows, [2]string{name, info.Rules[name]})
+			}
+			addDetailRows(result, rows)
+		}()
+	})
+
+	top := container.NewBorder(nil, nil, nil, queryBtn, addressEntry)
+	quickQueryWindow.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(result)))
+	quickQueryWindow.Resize(fyne.NewSize(400, 600))
+	quickQueryWindow.Show()
 }
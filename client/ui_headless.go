@@ -0,0 +1,8 @@
+//go:build headless
+
+package client
+
+// Start falls back to StartHeadless as GUI is not compiled into headless builds.
+func Start() {
+	StartHeadless()
+}
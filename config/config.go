@@ -1,13 +1,11 @@
 package config
 
 import (
-	"fyne.io/fyne/v2/app"
 	"github.com/comoyi/steam-server-monitor/log"
 	"github.com/comoyi/steam-server-monitor/util/fsutil"
 	"github.com/spf13/viper"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sync"
 )
 
@@ -18,18 +16,141 @@ type Config struct {
 	EnableApi bool      `toml:"enable_api" mapstructure:"enable_api"`
 	ApiPort   int64     `toml:"api_port" mapstructure:"api_port"`
 	Servers   []*Server `toml:"servers" mapstructure:"servers"`
+
+	// empty for all interfaces
+	ApiBind   string      `toml:"api_bind" mapstructure:"api_bind"`
+	ApiTokens []*ApiToken `toml:"api_tokens" mapstructure:"api_tokens"`
+	// seconds
+	ApiReadTimeout  int64 `toml:"api_read_timeout" mapstructure:"api_read_timeout"`
+	ApiWriteTimeout int64 `toml:"api_write_timeout" mapstructure:"api_write_timeout"`
+	ApiIdleTimeout  int64 `toml:"api_idle_timeout" mapstructure:"api_idle_timeout"`
+	// serve https when both are set
+	ApiTlsCert string `toml:"api_tls_cert" mapstructure:"api_tls_cert"`
+	ApiTlsKey  string `toml:"api_tls_key" mapstructure:"api_tls_key"`
+	// max ad-hoc queries per minute of /api/v1/query for each client ip, 0 means unlimited
+	ApiQueryRateLimit int64 `toml:"api_query_rate_limit" mapstructure:"api_query_rate_limit"`
+	// ip or cidr the ad-hoc query may target, empty for all public addresses, local and private ones must be listed
+	ApiQueryAllowlist []string `toml:"api_query_allowlist" mapstructure:"api_query_allowlist"`
+
+	QueryWorkers     int64   `toml:"query_workers" mapstructure:"query_workers"`
+	QueryRateLimit   float64 `toml:"query_rate_limit" mapstructure:"query_rate_limit"`
+	QueryStartJitter int64   `toml:"query_start_jitter" mapstructure:"query_start_jitter"`
+
+	QueryTimeoutMs      int64 `toml:"query_timeout_ms" mapstructure:"query_timeout_ms"`
+	QueryRetries        int64 `toml:"query_retries" mapstructure:"query_retries"`
+	QueryRetryBackoffMs int64 `toml:"query_retry_backoff_ms" mapstructure:"query_retry_backoff_ms"`
+	MaxBackoffInterval  int64 `toml:"max_backoff_interval" mapstructure:"max_backoff_interval"`
+
+	LatencyWindow int64 `toml:"latency_window" mapstructure:"latency_window"`
+
+	HistoryEnabled         bool  `toml:"history_enabled" mapstructure:"history_enabled"`
+	HistoryRawRetention    int64 `toml:"history_raw_retention" mapstructure:"history_raw_retention"`
+	HistoryRollupRetention int64 `toml:"history_rollup_retention" mapstructure:"history_rollup_retention"`
+	HistoryRollupInterval  int64 `toml:"history_rollup_interval" mapstructure:"history_rollup_interval"`
+
+	HistorySessionRetention int64 `toml:"history_session_retention" mapstructure:"history_session_retention"`
+
+	NotifyOfflineFailures int64 `toml:"notify_offline_failures" mapstructure:"notify_offline_failures"`
+	NotifyHysteresis      int64 `toml:"notify_hysteresis" mapstructure:"notify_hysteresis"`
+	NotifyCooldown        int64 `toml:"notify_cooldown" mapstructure:"notify_cooldown"`
+
+	Alerts              []*Alert `toml:"alerts" mapstructure:"alerts"`
+	AlertRetries        int64    `toml:"alert_retries" mapstructure:"alert_retries"`
+	AlertRetryBackoffMs int64    `toml:"alert_retry_backoff_ms" mapstructure:"alert_retry_backoff_ms"`
+	AlertTimeoutMs      int64    `toml:"alert_timeout_ms" mapstructure:"alert_timeout_ms"`
+	AlertHistorySize    int64    `toml:"alert_history_size" mapstructure:"alert_history_size"`
+
+	Targets []*Target `toml:"targets" mapstructure:"targets"`
 }
 
 type Server struct {
+	// generated on first load
+	Id          string `toml:"id" mapstructure:"id"`
 	DisplayName string `toml:"display_name" mapstructure:"display_name"`
 	Ip          string `toml:"ip" mapstructure:"ip"`
 	Port        int64  `toml:"port" mapstructure:"port"`
 	Interval    int64  `toml:"interval" mapstructure:"interval"`
 	Remark      string `toml:"remark" mapstructure:"remark"`
+	QueryRules  bool   `toml:"query_rules" mapstructure:"query_rules"`
+
+	TimeoutMs          *int64 `toml:"timeout_ms" mapstructure:"timeout_ms"`
+	Retries            *int64 `toml:"retries" mapstructure:"retries"`
+	RetryBackoffMs     *int64 `toml:"retry_backoff_ms" mapstructure:"retry_backoff_ms"`
+	MaxBackoffInterval *int64 `toml:"max_backoff_interval" mapstructure:"max_backoff_interval"`
+
+	Notify                bool  `toml:"notify" mapstructure:"notify"`
+	NotifyPlayerThreshold int64 `toml:"notify_player_threshold" mapstructure:"notify_player_threshold"`
+}
+
+type Alert struct {
+	Name string `toml:"name" mapstructure:"name"`
+	// id, address or display name of the server, empty for all servers
+	Server    string `toml:"server" mapstructure:"server"`
+	Condition string `toml:"condition" mapstructure:"condition"`
+	// seconds
+	Cooldown int64  `toml:"cooldown" mapstructure:"cooldown"`
+	Webhook  string `toml:"webhook" mapstructure:"webhook"`
+	// text/template of the JSON body, empty for the default body
+	Body string `toml:"body" mapstructure:"body"`
+	// name of a target to deliver to instead of the webhook
+	Target string `toml:"target" mapstructure:"target"`
+}
+
+// Target is a Discord or Slack destination of status changes and alerts.
+type Target struct {
+	Name string `toml:"name" mapstructure:"name"`
+	// discord or slack
+	Type    string `toml:"type" mapstructure:"type"`
+	Webhook string `toml:"webhook" mapstructure:"webhook"`
+	// slack bot token and channel, required to edit the live status message
+	Token   string `toml:"token" mapstructure:"token"`
+	Channel string `toml:"channel" mapstructure:"channel"`
+	// id, address or display name of the servers, empty for all servers
+	Servers []string `toml:"servers" mapstructure:"servers"`
+	// post a message when the status of a server changes
+	Events bool `toml:"events" mapstructure:"events"`
+	// keep one message with the status of all servers and edit it in place
+	Live bool `toml:"live" mapstructure:"live"`
+	// seconds, the min interval between two edits of the live message
+	LiveInterval int64 `toml:"live_interval" mapstructure:"live_interval"`
+}
+
+// ApiToken is a bearer token of the api, the api is open when no token is configured.
+type ApiToken struct {
+	Name  string `toml:"name" mapstructure:"name"`
+	Token string `toml:"token" mapstructure:"token"`
+	// read or admin, admin is required to modify servers
+	Scope string `toml:"scope" mapstructure:"scope"`
+	// max requests per minute, 0 means unlimited
+	RateLimit int64 `toml:"rate_limit" mapstructure:"rate_limit"`
 }
 
 func initDefaultConfig() {
 	viper.SetDefault("log_level", log.Off)
+	viper.SetDefault("api_read_timeout", 10)
+	viper.SetDefault("api_write_timeout", 30)
+	viper.SetDefault("api_idle_timeout", 120)
+	viper.SetDefault("api_query_rate_limit", 10)
+	viper.SetDefault("query_workers", 8)
+	viper.SetDefault("query_rate_limit", 20)
+	viper.SetDefault("query_start_jitter", 5)
+	viper.SetDefault("query_timeout_ms", 3000)
+	viper.SetDefault("query_retries", 1)
+	viper.SetDefault("query_retry_backoff_ms", 500)
+	viper.SetDefault("max_backoff_interval", 300)
+	viper.SetDefault("latency_window", 10)
+	viper.SetDefault("history_enabled", true)
+	viper.SetDefault("history_raw_retention", 7)
+	viper.SetDefault("history_rollup_retention", 365)
+	viper.SetDefault("history_rollup_interval", 3600)
+	viper.SetDefault("history_session_retention", 365)
+	viper.SetDefault("notify_offline_failures", 3)
+	viper.SetDefault("notify_hysteresis", 2)
+	viper.SetDefault("notify_cooldown", 300)
+	viper.SetDefault("alert_retries", 3)
+	viper.SetDefault("alert_retry_backoff_ms", 1000)
+	viper.SetDefault("alert_timeout_ms", 10000)
+	viper.SetDefault("alert_history_size", 100)
 }
 
 func LoadConfig() {
@@ -38,7 +159,7 @@ func LoadConfig() {
 	viper.SetConfigType("toml")
 	viper.AddConfigPath(".")
 
-	configDirPath, err := getConfigDirPath()
+	configDirPath, err := GetConfigDirPath()
 	if err != nil {
 		log.Warnf("Get configDirPath failed, err: %v\n", err)
 		return
@@ -61,6 +182,56 @@ func LoadConfig() {
 	log.Debugf("config: %+v\n", Conf)
 }
 
+// ApiSettings are the api settings which can be changed while running.
+type ApiSettings struct {
+	Enable bool
+	Bind   string
+	Port   int64
+}
+
+// apiMutex guards the api settings of Conf and apiListeners
+var apiMutex = &sync.RWMutex{}
+
+var apiListeners = make([]func(), 0)
+
+// AddApiListener registers a listener called after the api settings are changed by SetApi.
+func AddApiListener(listener func()) {
+	apiMutex.Lock()
+	defer apiMutex.Unlock()
+	apiListeners = append(apiListeners, listener)
+}
+
+// GetApiSettings returns a copy of the api settings, it is safe to call while SetApi runs.
+func GetApiSettings() ApiSettings {
+	apiMutex.RLock()
+	defer apiMutex.RUnlock()
+	return ApiSettings{
+		Enable: Conf.EnableApi,
+		Bind:   Conf.ApiBind,
+		Port:   Conf.ApiPort,
+	}
+}
+
+// SetApi changes and saves the api settings, the listeners are called even if saving failed.
+func SetApi(enable bool, bind string, port int64) error {
+	apiMutex.Lock()
+	Conf.EnableApi = enable
+	Conf.ApiBind = bind
+	Conf.ApiPort = port
+	viper.Set("enable_api", enable)
+	viper.Set("api_bind", bind)
+	viper.Set("api_port", port)
+	listeners := make([]func(), len(apiListeners))
+	copy(listeners, apiListeners)
+	apiMutex.Unlock()
+
+	err := SaveConfig()
+	for _, listener := range listeners {
+		listener()
+	}
+	return err
+}
+
 var saveMutex = &sync.Mutex{}
 
 func SaveConfig() error {
@@ -72,7 +243,7 @@ func SaveConfig() error {
 		return nil
 	}
 
-	configDirPath, err := getConfigDirPath()
+	configDirPath, err := GetConfigDirPath()
 	if err != nil {
 		log.Warnf("Get configDirPath failed, err: %v\n", err)
 		return err
@@ -101,7 +272,7 @@ func SaveConfig() error {
 	return nil
 }
 
-func getConfigDirPath() (string, error) {
+func GetConfigDirPath() (string, error) {
 	configRootPath, err := getConfigRootPath()
 	if err != nil {
 		return "", err
@@ -110,17 +281,3 @@ func getConfigDirPath() (string, error) {
 	configPath := filepath.Join(configRootPath, ".steam-server-monitor")
 	return configPath, nil
 }
-
-func getConfigRootPath() (string, error) {
-	var err error
-	configRootPath := ""
-	if runtime.GOOS == "android" {
-		configRootPath = app.NewWithID("com.comoyi.steamservermonitor").Storage().RootURI().Path()
-	} else {
-		configRootPath, err = os.UserHomeDir()
-		if err != nil {
-			return "", err
-		}
-	}
-	return configRootPath, nil
-}
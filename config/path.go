@@ -0,0 +1,9 @@
+//go:build !android
+
+package config
+
+import "os"
+
+func getConfigRootPath() (string, error) {
+	return os.UserHomeDir()
+}
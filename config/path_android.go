@@ -0,0 +1,9 @@
+//go:build android
+
+package config
+
+import "fyne.io/fyne/v2/app"
+
+func getConfigRootPath() (string, error) {
+	return app.NewWithID("com.comoyi.steamservermonitor").Storage().RootURI().Path(), nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/spf13/viper"
 	"io/fs"
 	"os"
+	"sync"
 )
 
 const (
@@ -65,8 +66,23 @@ func Errorf(format string, args ...interface{}) {
 	w(s)
 }
 
+var (
+	path   = "log.log"
+	pathMu sync.RWMutex
+)
+
+// SetPath changes the file the log is written to.
+func SetPath(p string) {
+	pathMu.Lock()
+	defer pathMu.Unlock()
+	path = p
+}
+
 func w(s string) {
-	file, err := os.OpenFile("log.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, fs.ModePerm)
+	pathMu.RLock()
+	p := path
+	pathMu.RUnlock()
+	file, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fs.ModePerm)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,255 @@
+package store
+
+import (
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// Sample is the result of a poll.
+type Sample struct {
+	// unix time in seconds
+	Time        int64   `json:"time"`
+	Status      string  `json:"status"`
+	PlayerCount int64   `json:"player_count"`
+	MaxPlayers  int64   `json:"max_players"`
+	LatencyMs   float64 `json:"latency_ms"`
+}
+
+// Rollup aggregates the samples of a period.
+type Rollup struct {
+	// unix time in seconds of the start of the period
+	Time           int64   `json:"time"`
+	Samples        int64   `json:"samples"`
+	OnlineSamples  int64   `json:"online_samples"`
+	PlayerCountMin int64   `json:"player_count_min"`
+	PlayerCountAvg float64 `json:"player_count_avg"`
+	PlayerCountMax int64   `json:"player_count_max"`
+	MaxPlayers     int64   `json:"max_players"`
+	LatencyAvgMs   float64 `json:"latency_avg_ms"`
+}
+
+// statuses of a sample, the same as the status of the client
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+func (s *Store) AddSample(serverKey string, sample *Sample) error {
+	// the add time makes the key unique when several polls finish in the same second
+	key := append(timeKey(sample.Time), timeKey(time.Now().UnixNano())...)
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		return putWithKey(tx, samplesBucket, serverKey, key, sample)
+	})
+}
+
+// Samples returns the raw samples of the server in [from, to).
+func (s *Store) Samples(serverKey string, from time.Time, to time.Time) ([]*Sample, error) {
+	samples := make([]*Sample, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scan(tx, samplesBucket, serverKey, from.Unix(), to.Unix(), func(t int64, data []byte) error {
+			sample := &Sample{}
+			if err := json.Unmarshal(data, sample); err != nil {
+				return err
+			}
+			samples = append(samples, sample)
+			return nil
+		})
+	})
+	return samples, err
+}
+
+// Rollups returns the rollups of the server with start time in [from, to).
+func (s *Store) Rollups(serverKey string, from time.Time, to time.Time) ([]*Rollup, error) {
+	rollups := make([]*Rollup, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scan(tx, rollupsBucket, serverKey, from.Unix(), to.Unix(), func(t int64, data []byte) error {
+			rollup := &Rollup{}
+			if err := json.Unmarshal(data, rollup); err != nil {
+				return err
+			}
+			rollups = append(rollups, rollup)
+			return nil
+		})
+	})
+	return rollups, err
+}
+
+// HistoryPoints returns at most points samples of the server in [from, to), one for each of the
+// equal buckets the range is split into. A bucket at least a rollup period long, or older than the
+// raw samples, takes the rollups in it, a shorter one takes its first raw sample, so the cost
+// depends on points rather than on the number of samples in the range.
+func (s *Store) HistoryPoints(serverKey string, from time.Time, to time.Time, points int) ([]*Sample, error) {
+	samples := make([]*Sample, 0, points)
+	start := from.Unix()
+	span := to.Unix() - start
+	if points <= 0 || span <= 0 {
+		return samples, nil
+	}
+	interval := int64(s.options.RollupInterval / time.Second)
+	useRollups := span >= interval*int64(points)
+	var rawStart int64 = 0
+	if s.options.RawRetention > 0 {
+		rawStart = time.Now().Add(-s.options.RawRetention).Unix()
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(samplesBucket).Bucket([]byte(serverKey))
+		rollups := tx.Bucket(rollupsBucket).Bucket([]byte(serverKey))
+		// the end of the rolled up periods, the raw samples after it are not rolled up yet
+		var rolledUp int64 = 0
+		if rollups != nil {
+			if k, _ := rollups.Cursor().Last(); k != nil {
+				rolledUp = keyTime(k) + interval
+			}
+		}
+		for i := 0; i < points; i++ {
+			bucketFrom := start + span*int64(i)/int64(points)
+			bucketTo := start + span*int64(i+1)/int64(points)
+			if bucketTo <= bucketFrom {
+				continue
+			}
+			var sample *Sample
+			var err error
+			if rollups != nil && (bucketTo <= rawStart || useRollups && bucketTo <= rolledUp) {
+				sample, err = bucketRollup(rollups.Cursor(), bucketFrom, bucketTo)
+			} else if raw != nil {
+				sample, err = firstSample(raw.Cursor(), bucketFrom, bucketTo)
+			}
+			if err != nil {
+				return err
+			}
+			if sample != nil {
+				samples = append(samples, sample)
+			}
+		}
+		return nil
+	})
+	return samples, err
+}
+
+// firstSample returns the first raw sample in [from, to), nil when there is none.
+func firstSample(c *bolt.Cursor, from int64, to int64) (*Sample, error) {
+	k, v := c.Seek(timeKey(from))
+	if k == nil || keyTime(k) >= to {
+		return nil, nil
+	}
+	sample := &Sample{}
+	if err := json.Unmarshal(v, sample); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// bucketRollup returns the rollup in [from, to) with the most players as a sample, an online one
+// is preferred, nil when there is none.
+func bucketRollup(c *bolt.Cursor, from int64, to int64) (*Sample, error) {
+	var result *Sample
+	for k, v := c.Seek(timeKey(from)); k != nil && keyTime(k) < to; k, v = c.Next() {
+		rollup := &Rollup{}
+		if err := json.Unmarshal(v, rollup); err != nil {
+			return nil, err
+		}
+		sample := rollup.toSample()
+		switch {
+		case result == nil:
+			result = sample
+		case result.Status == StatusOffline && sample.Status != StatusOffline:
+			result = sample
+		case sample.Status != StatusOffline && sample.PlayerCount > result.PlayerCount:
+			result = sample
+		}
+	}
+	return result, nil
+}
+
+func (r *Rollup) toSample() *Sample {
+	status := StatusOffline
+	if r.OnlineSamples > 0 {
+		status = StatusOnline
+	}
+	return &Sample{
+		Time:        r.Time,
+		Status:      status,
+		PlayerCount: int64(r.PlayerCountAvg + 0.5),
+		MaxPlayers:  r.MaxPlayers,
+		LatencyMs:   r.LatencyAvgMs,
+	}
+}
+
+// rollup aggregates the raw samples after the latest rollup of each server up to the current period.
+func (s *Store) rollup(tx *bolt.Tx, now time.Time) error {
+	interval := int64(s.options.RollupInterval / time.Second)
+	currentPeriod := now.Unix() / interval * interval
+	keys, err := serverKeys(tx, samplesBucket)
+	if err != nil {
+		return err
+	}
+	for _, serverKey := range keys {
+		if err := s.rollupServer(tx, serverKey, interval, currentPeriod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) rollupServer(tx *bolt.Tx, serverKey string, interval int64, currentPeriod int64) error {
+	var from int64 = 0
+	if b := tx.Bucket(rollupsBucket).Bucket([]byte(serverKey)); b != nil {
+		if k, _ := b.Cursor().Last(); k != nil {
+			from = keyTime(k) + interval
+		}
+	}
+
+	var current *Rollup
+	var latencySum float64 = 0
+	var playerCountSum int64 = 0
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		current.PlayerCountAvg = float64(playerCountSum) / float64(current.Samples)
+		if current.OnlineSamples > 0 {
+			current.LatencyAvgMs = latencySum / float64(current.OnlineSamples)
+		}
+		return put(tx, rollupsBucket, serverKey, current.Time, current)
+	}
+	err := scan(tx, samplesBucket, serverKey, from, currentPeriod, func(t int64, data []byte) error {
+		sample := &Sample{}
+		if err := json.Unmarshal(data, sample); err != nil {
+			return err
+		}
+		period := t / interval * interval
+		if current == nil || current.Time != period {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = &Rollup{
+				Time:           period,
+				PlayerCountMin: sample.PlayerCount,
+			}
+			latencySum = 0
+			playerCountSum = 0
+		}
+		current.Samples++
+		playerCountSum += sample.PlayerCount
+		if sample.PlayerCount < current.PlayerCountMin {
+			current.PlayerCountMin = sample.PlayerCount
+		}
+		if sample.PlayerCount > current.PlayerCountMax {
+			current.PlayerCountMax = sample.PlayerCount
+		}
+		if sample.MaxPlayers > current.MaxPlayers {
+			current.MaxPlayers = sample.MaxPlayers
+		}
+		if sample.Status != StatusOffline {
+			current.OnlineSamples++
+			latencySum += sample.LatencyMs
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"github.com/comoyi/steam-server-monitor/log"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, options Options) *Store {
+	dir := t.TempDir()
+	log.SetPath(filepath.Join(dir, "log.log"))
+	t.Cleanup(func() {
+		log.SetPath("log.log")
+	})
+	s, err := Open(filepath.Join(dir, "history.db"), options)
+	if err != nil {
+		t.Fatalf("Open failed, err: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+// addSamples adds an online sample every step in [from, to).
+func addSamples(t *testing.T, s *Store, serverKey string, from time.Time, to time.Time, step time.Duration) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for ts := from; ts.Before(to); ts = ts.Add(step) {
+			sample := &Sample{Time: ts.Unix(), Status: StatusOnline, PlayerCount: ts.Unix() / 60 % 50, MaxPlayers: 50}
+			if err := put(tx, samplesBucket, serverKey, ts.Unix(), sample); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("add samples failed, err: %v", err)
+	}
+}
+
+// checkBuckets checks there is at most one sample in each bucket, in time order.
+func checkBuckets(t *testing.T, samples []*Sample, from time.Time, to time.Time, points int) {
+	if len(samples) > points {
+		t.Errorf("got %d samples, want at most %d", len(samples), points)
+	}
+	span := to.Unix() - from.Unix()
+	last := -1
+	for _, sample := range samples {
+		i := int((sample.Time - from.Unix()) * int64(points) / span)
+		if i <= last || i >= points {
+			t.Errorf("sample at %d is in bucket %d after bucket %d", sample.Time, i, last)
+		}
+		last = i
+	}
+}
+
+func TestHistoryPointsRaw(t *testing.T) {
+	s := openTestStore(t, Options{RawRetention: 7 * 24 * time.Hour})
+	to := time.Now()
+	from := to.Add(-2 * time.Hour)
+	addSamples(t, s, "a", from.Add(-time.Hour), to, 10*time.Second)
+
+	samples, err := s.HistoryPoints("a", from, to, 120)
+	if err != nil {
+		t.Fatalf("HistoryPoints failed, err: %v", err)
+	}
+	if len(samples) != 120 {
+		t.Errorf("got %d samples, want 120", len(samples))
+	}
+	checkBuckets(t, samples, from, to, 120)
+
+	if samples, _ := s.HistoryPoints("b", from, to, 120); len(samples) != 0 {
+		t.Errorf("got %d samples of an unknown server", len(samples))
+	}
+	if samples, _ := s.HistoryPoints("a", to, from, 120); len(samples) != 0 {
+		t.Errorf("got %d samples of an empty range", len(samples))
+	}
+}
+
+func TestHistoryPointsRollups(t *testing.T) {
+	s := openTestStore(t, Options{RawRetention: 7 * 24 * time.Hour})
+	to := time.Now()
+	from := to.Add(-3 * 24 * time.Hour)
+	addSamples(t, s, "a", from, to, time.Minute)
+	if err := s.Maintain(to); err != nil {
+		t.Fatalf("Maintain failed, err: %v", err)
+	}
+
+	samples, err := s.HistoryPoints("a", from, to, 24)
+	if err != nil {
+		t.Fatalf("HistoryPoints failed, err: %v", err)
+	}
+	checkBuckets(t, samples, from, to, 24)
+	if len(samples) < 23 {
+		t.Fatalf("got %d samples, want at least 23", len(samples))
+	}
+	// the buckets are 3 hours, all but the last one which is not rolled up yet take the rollups
+	for _, sample := range samples[:len(samples)-1] {
+		if sample.Time%3600 != 0 {
+			t.Errorf("sample at %d is not a rollup", sample.Time)
+		}
+	}
+}
+
+func TestHistoryPointsExpiredRaw(t *testing.T) {
+	s := openTestStore(t, Options{RawRetention: 24 * time.Hour})
+	to := time.Now()
+	from := to.Add(-3 * 24 * time.Hour)
+	addSamples(t, s, "a", from, to, time.Minute)
+	if err := s.Maintain(to); err != nil {
+		t.Fatalf("Maintain failed, err: %v", err)
+	}
+
+	// 6 minute buckets are shorter than a rollup, only the expired part takes the rollups
+	samples, err := s.HistoryPoints("a", from, to, 720)
+	if err != nil {
+		t.Fatalf("HistoryPoints failed, err: %v", err)
+	}
+	checkBuckets(t, samples, from, to, 720)
+	rawStart := to.Add(-24 * time.Hour).Unix()
+	rollups := 0
+	for _, sample := range samples {
+		if sample.Time < rawStart {
+			rollups++
+			if sample.Time%3600 != 0 {
+				t.Errorf("sample at %d is an expired raw sample", sample.Time)
+			}
+		}
+	}
+	if rollups < 47 {
+		t.Errorf("got %d rollups of the expired part, want at least 47", rollups)
+	}
+	if raw := len(samples) - rollups; raw < 239 {
+		t.Errorf("got %d raw samples, want at least 239", raw)
+	}
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// Session is a continuous stay of a player on a server.
+type Session struct {
+	Name string `json:"name"`
+	// unix time in seconds
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	// seconds
+	Duration int64 `json:"duration"`
+}
+
+// AddSession stores a completed session, sessions are ordered by end time.
+func (s *Store) AddSession(serverKey string, session *Session) error {
+	// the name makes the key unique when several players leave at the same time
+	key := append(timeKey(session.EndTime), []byte(session.Name)...)
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		return putWithKey(tx, sessionsBucket, serverKey, key, session)
+	})
+}
+
+// Sessions returns the sessions of the server which ended in [from, to).
+func (s *Store) Sessions(serverKey string, from time.Time, to time.Time) ([]*Session, error) {
+	sessions := make([]*Session, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return scan(tx, sessionsBucket, serverKey, from.Unix(), to.Unix(), func(t int64, data []byte) error {
+			session := &Session{}
+			if err := json.Unmarshal(data, session); err != nil {
+				return err
+			}
+			sessions = append(sessions, session)
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// AllSessions returns the sessions of every server which ended in [from, to), keyed by server.
+func (s *Store) AllSessions(from time.Time, to time.Time) (map[string][]*Session, error) {
+	all := make(map[string][]*Session)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		keys, err := serverKeys(tx, sessionsBucket)
+		if err != nil {
+			return err
+		}
+		for _, serverKey := range keys {
+			sessions := make([]*Session, 0)
+			err = scan(tx, sessionsBucket, serverKey, from.Unix(), to.Unix(), func(t int64, data []byte) error {
+				session := &Session{}
+				if err := json.Unmarshal(data, session); err != nil {
+					return err
+				}
+				sessions = append(sessions, session)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			all[serverKey] = sessions
+		}
+		return nil
+	})
+	return all, err
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/comoyi/steam-server-monitor/log"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	samplesBucket  = []byte("samples")
+	rollupsBucket  = []byte("rollups")
+	sessionsBucket = []byte("sessions")
+)
+
+type Options struct {
+	// how long raw samples are kept
+	RawRetention time.Duration
+	// how long rollups are kept
+	RollupRetention time.Duration
+	// how long player sessions are kept
+	SessionRetention time.Duration
+	// period of a rollup
+	RollupInterval time.Duration
+}
+
+// Store is an on-disk time-series store of server samples, keyed by server.
+type Store struct {
+	db      *bolt.DB
+	options Options
+}
+
+func Open(path string, options Options) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{samplesBucket, rollupsBucket, sessionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if options.RollupInterval <= 0 {
+		options.RollupInterval = time.Hour
+	}
+	return &Store{
+		db:      db,
+		options: options,
+	}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func timeKey(t int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t))
+	return key
+}
+
+func keyTime(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key))
+}
+
+func put(tx *bolt.Tx, bucket []byte, serverKey string, t int64, v interface{}) error {
+	return putWithKey(tx, bucket, serverKey, timeKey(t), v)
+}
+
+// putWithKey puts v with a key which starts with the time key.
+func putWithKey(tx *bolt.Tx, bucket []byte, serverKey string, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(serverKey))
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// scan calls fn with the values of serverKey in [from, to) in time order.
+func scan(tx *bolt.Tx, bucket []byte, serverKey string, from int64, to int64, fn func(t int64, data []byte) error) error {
+	b := tx.Bucket(bucket).Bucket([]byte(serverKey))
+	if b == nil {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.Seek(timeKey(from)); k != nil && keyTime(k) < to; k, v = c.Next() {
+		if err := fn(keyTime(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serverKeys returns the keys of the servers in bucket.
+func serverKeys(tx *bolt.Tx, bucket []byte) ([]string, error) {
+	keys := make([]string, 0)
+	err := tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	return keys, err
+}
+
+// deleteBefore deletes the values older than t of all servers in bucket.
+func deleteBefore(tx *bolt.Tx, bucket []byte, t int64) (int, error) {
+	keys, err := serverKeys(tx, bucket)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, serverKey := range keys {
+		b := tx.Bucket(bucket).Bucket([]byte(serverKey))
+		if b == nil {
+			continue
+		}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && keyTime(k) < t; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// StartMaintenance rolls up and expires data periodically until stop is closed.
+func (s *Store) StartMaintenance(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Maintain(time.Now()); err != nil {
+				log.Warnf("store maintain failed, err: %v\n", err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Maintain rolls up the raw samples of the finished periods and deletes the expired data.
+func (s *Store) Maintain(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := s.rollup(tx, now); err != nil {
+			return err
+		}
+		if s.options.RawRetention > 0 {
+			count, err := deleteBefore(tx, samplesBucket, now.Add(-s.options.RawRetention).Unix())
+			if err != nil {
+				return err
+			}
+			log.Debugf("store expired samples: %d\n", count)
+		}
+		if s.options.RollupRetention > 0 {
+			count, err := deleteBefore(tx, rollupsBucket, now.Add(-s.options.RollupRetention).Unix())
+			if err != nil {
+				return err
+			}
+			log.Debugf("store expired rollups: %d\n", count)
+		}
+		if s.options.SessionRetention > 0 {
+			count, err := deleteBefore(tx, sessionsBucket, now.Add(-s.options.SessionRetention).Unix())
+			if err != nil {
+				return err
+			}
+			log.Debugf("store expired sessions: %d\n", count)
+		}
+		return nil
+	})
+}
+
+// RenameServer moves the data of oldKey to newKey, nothing is moved when newKey already has data.
+func (s *Store) RenameServer(oldKey string, newKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{samplesBucket, rollupsBucket, sessionsBucket} {
+			parent := tx.Bucket(name)
+			old := parent.Bucket([]byte(oldKey))
+			if old == nil || parent.Bucket([]byte(newKey)) != nil {
+				continue
+			}
+			b, err := parent.CreateBucket([]byte(newKey))
+			if err != nil {
+				return err
+			}
+			err = old.ForEach(func(k, v []byte) error {
+				return b.Put(k, v)
+			})
+			if err != nil {
+				return err
+			}
+			if err := parent.DeleteBucket([]byte(oldKey)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}